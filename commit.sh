@@ -41,7 +41,7 @@ then
 	exit 1
 fi
 
-for TEST in readers strio bake/template bake/recipe/test bake/proj bake
+for TEST in readers strio bake/template bake/recipe bake/recipe/install bake/recipe/test bake/proj bake
 do
 	go test -i $TEST
 	go test $TEST
@@ -12,45 +12,42 @@
 
 # This script applies formatting rules to bake include files.
 
-for LANG in templates/*
+for TYPES in recipes/*/types
 do
-	if [ -f $LANG/*.fmt ]
+	if [ -f $TYPES/*.fmt ]
 	then
-		rm $LANG/*.fmt
+		rm $TYPES/*.fmt
 	fi
 
-	for INCL in $LANG/*
+	for INCL in $TYPES/*
 	do
-		if [ $(basename $INCL) != "{ProjectName}" ]
-		then
-			head -1 $INCL > $INCL.fmt
+		head -1 $INCL > $INCL.fmt
 
-			DESCR=$(cat $INCL.fmt)
-			if [ "$DESCR" = "" -a $(basename $INCL) != "base" ]
-			then
-				echo "File description for '$INCL' is empty"
-				exit 1
-			elif [ ${#DESCR} -gt 50 ]
-			then
-				echo "File description for '$INCL' is over 50 chars (${#DESCR})"
-				exit 1
-			fi
+		DESCR=$(cat $INCL.fmt)
+		if [ "$DESCR" = "" -a $(basename $INCL) != "base" ]
+		then
+			echo "File description for '$INCL' is empty"
+			exit 1
+		elif [ ${#DESCR} -gt 50 ]
+		then
+			echo "File description for '$INCL' is over 50 chars (${#DESCR})"
+			exit 1
+		fi
 
-			bin/fmtincl $INCL >> $INCL.fmt
+		bin/fmtincl $INCL >> $INCL.fmt
+		if [ $? -eq 0 ]
+		then
+			diff $INCL $INCL.fmt >/dev/null
 			if [ $? -eq 0 ]
 			then
-				diff $INCL $INCL.fmt >/dev/null
-				if [ $? -eq 0 ]
-				then
-					rm $INCL.fmt
-				else
-					echo "Formatting '$INCL'..."
-					mv $INCL.fmt $INCL
-				fi
-			else
 				rm $INCL.fmt
-				exit 1
+			else
+				echo "Formatting '$INCL'..."
+				mv $INCL.fmt $INCL
 			fi
+		else
+			rm $INCL.fmt
+			exit 1
 		fi
 	done
 done
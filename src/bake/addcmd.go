@@ -0,0 +1,156 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bake/proj"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// runAddCmd runs the `bake add` command with the arguments following `add`
+// and exits. It adds the given types to a project that was already generated,
+// either the project named by `-n` in the current directory or the current
+// directory itself, and generates only the files that the new types
+// contribute. The project is described by the settings that bake recorded when
+// it generated it, or, for projects generated before settings were recorded,
+// by the project options, in which case its types are inferred from the files
+// that it has.
+func runAddCmd(args []string) {
+	flags := projFlags("add")
+	flags.Parse(args)
+	validateFormat()
+	if flags.NArg() != 0 {
+		usage()
+		os.Exit(2)
+	} else if len(types) == 0 {
+		fmt.Fprintf(os.Stderr, "-t is required\n")
+		usage()
+		os.Exit(2)
+	}
+
+	// The project is generated to `dest`, which is the parent of the current
+	// directory if no name is given.
+	dir, dest := name, ""
+	if name == "" {
+		dir, dest = ".", ".."
+	}
+
+	settings, err := proj.ReadSettings(path.Join(dir, proj.SettingsFile))
+	if os.IsNotExist(err) {
+		settings, err = inferSettings(dir, dest)
+	} else if err == nil {
+		err = overrideSettings(settings)
+	}
+	if err == nil && dir == "." {
+		err = checkProjDir(settings.Vars["ProjectName"])
+	}
+	if err != nil {
+		printErr(err)
+		os.Exit(2)
+	}
+
+	pr, err := proj.New(settings.Lang, settings.Types, verbose, settings.Vars)
+	if err != nil {
+		printErr(err)
+		os.Exit(2)
+	}
+	if format == jsonFormat {
+		pr.SetOutput(ioutil.Discard)
+	}
+	if err := pr.AddTypes(dest, types); err != nil {
+		printErr(err)
+		os.Exit(2)
+	}
+
+	if format == jsonFormat {
+		printResults(settings.Vars["ProjectName"], &pr)
+	} else if !verbose {
+		// Verbose output already reports the files that differ.
+		for _, res := range pr.Results() {
+			if res.Status == proj.Conflicted {
+				fmt.Printf("'%s' would differ with the new types, so it "+
+					"wasn't changed\n", res.Path)
+			}
+		}
+	}
+	os.Exit(0)
+}
+
+// overrideSettings replaces the recorded variables in `s` with those of the
+// project options that were given, and returns an error if the options
+// describe a different project.
+func overrideSettings(s *proj.Settings) error {
+	if lang != "" && lang != s.Lang {
+		return fmt.Errorf("the project is in %s, not %s", s.Lang, lang)
+	} else if name != "" && name != s.Vars["ProjectName"] {
+		return fmt.Errorf("the project is named %s, not %s",
+			s.Vars["ProjectName"], name)
+	}
+
+	if owner != "" {
+		s.Vars["Owner"] = owner
+	}
+	addOptionalVars(s.Vars)
+	return nil
+}
+
+// checkProjDir returns an error if the current directory isn't the root
+// directory of the project named `projName`, which is where the project is
+// generated to from the parent directory.
+func checkProjDir(projName string) error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	} else if filepath.Base(wd) != projName {
+		return fmt.Errorf("the current directory isn't named after the "+
+			"project, run `%s add` from its parent with -n %s", os.Args[0],
+			projName)
+	}
+	return nil
+}
+
+// inferSettings returns the settings of the project in `dir`, which was
+// generated to `dest` without recording them, from the project options and
+// the files of the project.
+func inferSettings(dir, dest string) (*proj.Settings, error) {
+	if dir == "." {
+		return nil, fmt.Errorf("no %s file was found in the current "+
+			"directory, use -n to name the project", proj.SettingsFile)
+	} else if !isDir(dir) {
+		return nil, fmt.Errorf("'%s' isn't a project in this directory, "+
+			"use `%s new` to create it", dir, os.Args[0])
+	}
+
+	interactive := checkRequired()
+	if interactive {
+		if err := promptArgs(newPrompter(os.Stdin, os.Stderr)); err != nil {
+			return nil, err
+		}
+	}
+	validateLang(lang)
+
+	vars := makeProjVars()
+	addOptionalVars(vars)
+
+	ts, err := proj.InferTypes(lang, vars, dest)
+	if err != nil {
+		return nil, err
+	}
+	if verbose {
+		fmt.Printf("Inferred the types [%s] from the files of '%s'\n",
+			strings.Join(ts, ", "), dir)
+	}
+	return &proj.Settings{Lang: lang, Types: ts, Vars: vars}, nil
+}
+
+func isDir(p string) bool {
+	fi, err := os.Stat(p)
+	return err == nil && fi.IsDir()
+}
@@ -8,12 +8,12 @@ package main
 import (
 	"bake/env"
 	"bake/proj"
-	"bufio"
+	"bake/recipe"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
-	"path"
 	"sort"
 	"strconv"
 	"strings"
@@ -33,84 +33,253 @@ func (s *stringSlice) Set(vs string) error {
 	return nil
 }
 
+// The options that describe the project to generate.
 var (
-	types stringSlice
-
-	verbose   = flag.Bool("v", false, "Print extra progress information")
-	langTypes = flag.String("T", "", "Print project types for language")
-
-	helpArgs = map[*bool]func(){
-		flag.Bool("L", false, "Print supported languages"): printLangs,
-	}
+	types   stringSlice
+	verbose bool
 
+	email        string
 	optionalArgs = map[string]*string{
-		"Email": flag.String("e", "", "Email address of the owner"),
+		"Email": &email,
 	}
 
-	lang  = flag.String("l", "", "Language of the project")
-	owner = flag.String("o", "", "Owner of the project")
-	name  = flag.String("n", "", "Name of the project")
-
+	lang         string
+	owner        string
+	name         string
 	requiredArgs = map[string]*string{
-		"l": lang,
-		"o": owner,
-		"n": name,
+		"l": &lang,
+		"o": &owner,
+		"n": &name,
 	}
 )
 
-func main() {
-	flag.Var(&types, "t", "The project's types")
+// cmds maps the subcommands of bake to the functions that run them with the
+// remaining arguments and exit.
+var cmds = map[string]func(args []string){
+	"new":             runNewCmd,
+	"add":             runAddCmd,
+	"list":            runListCmd,
+	"vars":            runVarsCmd,
+	"test":            runTestCmd,
+	"recipe":          runRecipeCmd,
+	"check-templates": runCheckTemplatesCmd,
+	"completion":      runCompletionCmd,
+}
 
-	parseFlags()
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n"+
+		"  %[1]s new [project options]\n"+
+		"  %[1]s add -t types [project options]\n"+
+		"  %[1]s list langs|types lang|licenses [--format f]\n"+
+		"  %[1]s vars lang [-t types] [--format f]\n"+
+		"  %[1]s test [lang...]\n"+
+		"  %[1]s recipe install|list|remove ...\n"+
+		"  %[1]s check-templates lang\n"+
+		"  %[1]s completion bash|zsh|fish\n"+
+		"  %[1]s [project options]\n"+
+		"  %[1]s -L|--languages [--format f]\n"+
+		"  %[1]s -T|--types lang [--format f]\n"+
+		"\n"+
+		"Project options:\n"+
+		"  -l, --language lang  Language of the project\n"+
+		"  -o, --owner owner    Owner of the project\n"+
+		"  -n, --name name      Name of the project\n"+
+		"  -t, --type types     The project's types, separated by commas\n"+
+		"  -e, --email email    Email address of the owner\n"+
+		"  -v, --verbose        Print extra progress information\n"+
+		"\n"+
+		"Listing and project options:\n"+
+		"  --format text|json   Print the output as text or as JSON\n",
+		os.Args[0])
+}
 
-	validateLang(*lang)
+func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := cmds[os.Args[1]]; ok {
+			cmd(os.Args[2:])
+		}
+	}
 
-	vars := makeProjVars()
-	for argName, argVal := range optionalArgs {
-		if *argVal != "" {
-			vars[argName] = *argVal
+	// bake can also be run without a subcommand, as it was before it had
+	// them, in which case it generates a project unless it's asked to list
+	// languages or types.
+	flags := projFlags("bake")
+	printLangsArg := flags.Bool("L", false, "Print supported languages")
+	alias(flags, "L", "languages")
+	langTypes := flags.String("T", "", "Print project types for language")
+	alias(flags, "T", "types")
+	flags.Parse(os.Args[1:])
+	validateFormat()
+
+	if *langTypes != "" {
+		validateLang(*langTypes)
+		printTypesFor(os.Stdout, *langTypes, format)
+		os.Exit(0)
+	}
+	if *printLangsArg {
+		if err := printLangs(format); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
 		}
+		os.Exit(0)
 	}
 
-	p := proj.New(*lang, types, *verbose, vars)
-	if err := p.GenTo(""); err != nil {
-		fmt.Fprintf(os.Stderr, "%s\n", err)
+	if flags.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "'%s' is not a bake command\n", flags.Arg(0))
+		usage()
 		os.Exit(2)
 	}
+	genProj()
+	os.Exit(0)
 }
 
-func makeProjVars() map[string]string {
-	return map[string]string{
-		"ProjectName":      *name,
-		"ProjectNameLower": strings.ToLower(*name),
-		"Owner":            *owner,
-		"Year":             strconv.Itoa(time.Now().Year()),
+// projFlags returns the flags of the command `cmd`, which generates a project,
+// with a short and a long name for each option.
+func projFlags(cmd string) *flag.FlagSet {
+	flags := flag.NewFlagSet(cmd, flag.ExitOnError)
+	flags.Usage = usage
+
+	flags.StringVar(&lang, "l", "", "Language of the project")
+	alias(flags, "l", "language")
+	flags.StringVar(&owner, "o", "", "Owner of the project")
+	alias(flags, "o", "owner")
+	flags.StringVar(&name, "n", "", "Name of the project")
+	alias(flags, "n", "name")
+	flags.Var(&types, "t", "The project's types")
+	alias(flags, "t", "type")
+	flags.StringVar(&email, "e", "", "Email address of the owner")
+	alias(flags, "e", "email")
+	flags.BoolVar(&verbose, "v", false, "Print extra progress information")
+	alias(flags, "v", "verbose")
+	addFormatFlag(flags)
+
+	return flags
+}
+
+// alias makes `long` another name for the flag `short` in `flags`.
+func alias(flags *flag.FlagSet, short, long string) {
+	f := flags.Lookup(short)
+	flags.Var(f.Value, long, f.Usage)
+}
+
+// runNewCmd runs the `bake new` command with the arguments following `new`
+// and exits. It generates a new project in the current directory.
+func runNewCmd(args []string) {
+	flags := projFlags("new")
+	flags.Parse(args)
+	validateFormat()
+	if flags.NArg() != 0 {
+		usage()
+		os.Exit(2)
 	}
+
+	genProj()
+	os.Exit(0)
 }
 
-func parseFlags() {
-	flag.Parse()
+// genProj generates the project described by the project options, asking for
+// the missing required options if stdin is a terminal.
+func genProj() {
+	interactive := checkRequired()
 
-	if *langTypes != "" {
-		validateLang(*langTypes)
-		printTypesFor(*langTypes)
-		os.Exit(0)
+	var p *prompter
+	if interactive {
+		p = newPrompter(os.Stdin, os.Stderr)
+		if err := promptArgs(p); err != nil {
+			printErr(err)
+			os.Exit(2)
+		}
+	}
+
+	validateLang(lang)
+
+	vars := makeProjVars()
+	addOptionalVars(vars)
+
+	if interactive {
+		if err := promptVars(p, lang, types, vars); err != nil {
+			printErr(err)
+			os.Exit(2)
+		}
+	}
+
+	pr, err := proj.New(lang, types, verbose, vars)
+	if err != nil {
+		printErr(err)
+		os.Exit(2)
+	}
+	if format == jsonFormat {
+		pr.SetOutput(ioutil.Discard)
+	}
+	if err := pr.GenTo(""); err != nil {
+		printErr(err)
+		os.Exit(2)
+	}
+
+	if format == jsonFormat {
+		printResults(name, &pr)
 	}
+}
 
-	for argVal, printFunc := range helpArgs {
-		if *argVal {
-			printFunc()
-			os.Exit(0)
+// addOptionalVars sets the variables of the optional options that were given
+// in `vars`.
+func addOptionalVars(vars map[string]string) {
+	for argName, argVal := range optionalArgs {
+		if *argVal != "" {
+			vars[argName] = *argVal
 		}
 	}
+}
 
+// printResults prints the results of generating the project `p` named `name`
+// as a JSON document.
+func printResults(name string, p *proj.Project) {
+	results := p.Results()
+	if results == nil {
+		results = []proj.Result{}
+	}
+	err := printJSON(os.Stdout, struct {
+		Name    string        `json:"name"`
+		Results []proj.Result `json:"results"`
+	}{name, results})
+	if err != nil {
+		printErr(err)
+		os.Exit(2)
+	}
+}
+
+// checkRequired returns true if the missing required options should be
+// prompted for, which is only done when stdin is a terminal, and exits if any
+// are missing otherwise.
+func checkRequired() bool {
+	var missing []string
 	for argName, argVal := range requiredArgs {
 		if *argVal == "" {
-			fmt.Fprintf(os.Stderr, "-%s is required\n", argName)
-			flag.Usage()
-			os.Exit(2)
+			missing = append(missing, argName)
 		}
 	}
+
+	if len(missing) == 0 {
+		return false
+	} else if isTerminal(os.Stdin) {
+		return true
+	}
+	sort.Strings(missing)
+	for _, argName := range missing {
+		fmt.Fprintf(os.Stderr, "-%s is required\n", argName)
+	}
+	usage()
+	os.Exit(2)
+	return false
+}
+
+func makeProjVars() map[string]string {
+	return map[string]string{
+		"ProjectName":      name,
+		"ProjectNameLower": strings.ToLower(name),
+		"Owner":            owner,
+		"Year":             strconv.Itoa(time.Now().Year()),
+	}
 }
 
 func validateLang(lang string) {
@@ -131,58 +300,89 @@ func validateLang(lang string) {
 	}
 }
 
-func printTypesFor(lang string) {
-	tmplDir, err := env.TemplatesPath()
+// printTypesFor writes the types of `lang` to `w` in the format `f`, with the
+// source, the description and the dependencies of each.
+func printTypesFor(w io.Writer, lang, f string) {
+	docs, err := typeDocs(lang)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(2)
-	}
-
-	langDir := path.Join(tmplDir, lang)
-
-	fis, err := ioutil.ReadDir(langDir)
-	if len(fis) <= 2 {
+	} else if len(docs) == 0 {
 		fmt.Fprintf(os.Stderr, "'%s' is not fully supported\n", lang)
 		os.Exit(2)
 	}
 
-	typeNames := make([]string, len(fis)-2)
-	i := 0
-	for _, fi := range fis {
-		name := fi.Name()
-		if name != "base" && name != "{ProjectName}" {
-			typeNames[i] = name
-			i++
-		}
-	}
-
-	for _, name := range typeNames {
-		file, err := os.Open(path.Join(langDir, name))
+	if f == jsonFormat {
+		err = printJSON(w, struct {
+			Lang  string    `json:"language"`
+			Types []typeDoc `json:"types"`
+		}{lang, docs})
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%v\n", err)
 			os.Exit(2)
 		}
-		defer file.Close()
+		return
+	}
 
-		descr, err := bufio.NewReader(file).ReadString('\n')
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "%v\n", err)
-			os.Exit(2)
-		}
+	for _, doc := range docs {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", doc.Name, doc.Source, doc.Descr)
+	}
+}
+
+// typeDocs returns the types of `lang`, described by their include files.
+func typeDocs(lang string) ([]typeDoc, error) {
+	r, err := recipe.For(lang)
+	if err != nil {
+		return nil, err
+	}
+
+	infos, err := r.TypeInfos()
+	if err != nil {
+		return nil, err
+	}
 
-		fmt.Printf("%s\t%s", name, descr)
+	docs := make([]typeDoc, len(infos))
+	for i, info := range infos {
+		docs[i] = typeDoc{info.Name, info.Source.Name, info.Descr,
+			nonNil(info.Requires), nonNil(info.Conflicts)}
 	}
+	return docs, nil
 }
 
-func printLangs() {
-	langs, err := env.SupportedLangs()
+// nonNil returns `strs`, or an empty slice if `strs` is nil, so that it's
+// encoded as an empty JSON array rather than as null.
+func nonNil(strs []string) []string {
+	if strs == nil {
+		return []string{}
+	}
+	return strs
+}
 
+// printLangs prints the supported languages in the format `f`, with the
+// sources of each.
+func printLangs(f string) error {
+	langs, err := env.Langs()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
-		return
+		return err
 	}
 
-	for _, lang := range langs {
-		fmt.Printf("%s\n", lang)
+	docs := make([]langDoc, len(langs))
+	for i, lang := range langs {
+		docs[i].Name = lang.Name
+		docs[i].Sources = make([]string, len(lang.Sources))
+		for j, src := range lang.Sources {
+			docs[i].Sources[j] = src.Name
+		}
+	}
+
+	if f == jsonFormat {
+		return printJSON(os.Stdout, struct {
+			Langs []langDoc `json:"languages"`
+		}{docs})
 	}
+
+	for _, doc := range docs {
+		fmt.Printf("%s\t%s\n", doc.Name, strings.Join(doc.Sources, ", "))
+	}
+	return nil
 }
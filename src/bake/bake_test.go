@@ -5,11 +5,15 @@
 package main
 
 import (
+	"bake/proj"
 	"bufio"
+	"encoding/json"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path"
+	"reflect"
 	"sort"
 	"strings"
 	"testing"
@@ -122,6 +126,10 @@ func TestLanguagesArg(t *testing.T) {
 
 	langs := sort.StringSlice(strings.Split(output, "\n"))
 	langs = langs[:len(langs)-1] // trim extra newline
+	for i, lang := range langs {
+		// each language is followed by the sources that provide it
+		langs[i] = strings.Split(lang, "\t")[0]
+	}
 	if m, n := supportedLangs.Len(), langs.Len(); m != n {
 		t.Fatalf("Expected %d supported languages, got %d: '%s'",
 			m, n, strings.Join(langs, "','"))
@@ -227,3 +235,283 @@ func TestGenProjType(t *testing.T) {
 		t.Errorf("Expected '%s' to be a file", fname)
 	}
 }
+
+func TestListLangsCmd(t *testing.T) {
+	_, legacy, _ := runBake(t, "-L")
+	cmd, output, errput := runBake(t, "list", "langs")
+
+	if !cmd.ProcessState.Success() {
+		t.Fatalf("bake did not exit successfully")
+	}
+
+	if len(errput) > 0 {
+		t.Fatalf("Didn't expect error: %s", errput)
+	}
+
+	if output != legacy {
+		t.Fatalf("Expected '%s', got '%s'", legacy, output)
+	}
+}
+
+func TestNewAndAddCmds(t *testing.T) {
+	dir := os.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Error changing to directory: %v", err)
+	}
+
+	name := "Added"
+	if e := os.RemoveAll(name); e != nil && !os.IsNotExist(e) {
+		t.Fatalf("Error removing '%s': %v", name, e)
+	}
+
+	cmd, _, _ := runBake(t, "add", "--name", name, "--owner", "owner",
+		"--language", "go", "--type", "make")
+	if cmd.ProcessState.Success() {
+		t.Fatalf("bake exited successfully, expected failure")
+	}
+
+	_, _, errput := runBake(t, "new", "--name", name, "--owner", "owner",
+		"--language", "go", "--type", "bin")
+	if len(errput) != 0 {
+		t.Fatalf("stderr was not empty: %s", errput)
+	}
+
+	// The project is described by the settings that `new` recorded in it.
+	if err := os.Chdir(name); err != nil {
+		t.Fatalf("Error changing to directory: %v", err)
+	}
+	_, _, errput = runBake(t, "add", "-t", "make")
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Error changing to directory: %v", err)
+	}
+	if len(errput) != 0 {
+		t.Fatalf("stderr was not empty: %s", errput)
+	}
+
+	for _, fname := range []string{"README.md", "Makefile", proj.SettingsFile} {
+		fpath := path.Join(name, fname)
+		if _, err := os.Stat(fpath); err != nil {
+			t.Fatalf("File '%s' should exist: %v", fpath, err)
+		}
+	}
+
+	settings, err := proj.ReadSettings(path.Join(name, proj.SettingsFile))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{"bin", "make"}
+	if !reflect.DeepEqual(settings.Types, expected) {
+		t.Errorf("Expected recorded types %v, got %v", expected,
+			settings.Types)
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	cmd, output, errput := runBake(t, "list", "types", "go", "--format",
+		"json")
+	if !cmd.ProcessState.Success() {
+		t.Fatalf("bake did not exit successfully: %s", errput)
+	}
+
+	var types struct {
+		Lang  string `json:"language"`
+		Types []struct {
+			Name     string   `json:"name"`
+			Descr    string   `json:"description"`
+			Requires []string `json:"requires"`
+		} `json:"types"`
+	}
+	if err := json.Unmarshal([]byte(output), &types); err != nil {
+		t.Fatalf("Couldn't decode '%s': %v", output, err)
+	}
+	if types.Lang != "go" || len(types.Types) == 0 {
+		t.Fatalf("Expected the types of go, got '%s'", output)
+	}
+	for _, typ := range types.Types {
+		if typ.Descr == "" || typ.Requires == nil {
+			t.Errorf("Expected a description and requirements for '%s'",
+				typ.Name)
+		}
+	}
+
+	if err := os.Chdir(os.TempDir()); err != nil {
+		t.Fatalf("Error changing to directory: %v", err)
+	}
+	name := "Formatted"
+	if e := os.RemoveAll(name); e != nil && !os.IsNotExist(e) {
+		t.Fatalf("Error removing '%s': %v", name, e)
+	}
+
+	cmd, output, errput = runBake(t, "new", "-n", name, "-o", "owner", "-l",
+		"go", "--format", "json")
+	if !cmd.ProcessState.Success() {
+		t.Fatalf("bake did not exit successfully: %s", errput)
+	}
+
+	var gen struct {
+		Results []struct {
+			Path   string `json:"path"`
+			Source string `json:"source"`
+			Status string `json:"status"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(output), &gen); err != nil {
+		t.Fatalf("Couldn't decode '%s': %v", output, err)
+	}
+	if len(gen.Results) == 0 || gen.Results[0].Path != name ||
+		gen.Results[0].Source != "{ProjectName}" {
+
+		t.Fatalf("Expected the results for '%s', got '%s'", name, output)
+	}
+	for _, r := range gen.Results {
+		if r.Status != "created" {
+			t.Errorf("Expected '%s' to be created, got '%s'", r.Path,
+				r.Status)
+		}
+	}
+}
+
+func TestVarsJSONFormat(t *testing.T) {
+	cmd, output, errput := runBake(t, "vars", "go", "-t", "bin", "--format",
+		"json")
+	if !cmd.ProcessState.Success() {
+		t.Fatalf("bake did not exit successfully: %s", errput)
+	}
+
+	var vars struct {
+		Lang string `json:"language"`
+		Vars []struct {
+			Name     string   `json:"name"`
+			Kinds    []string `json:"kinds"`
+			Supplied bool     `json:"supplied"`
+		} `json:"variables"`
+	}
+	if err := json.Unmarshal([]byte(output), &vars); err != nil {
+		t.Fatalf("Couldn't decode '%s': %v", output, err)
+	}
+	if vars.Lang != "go" {
+		t.Fatalf("Expected the variables of go, got '%s'", output)
+	}
+	for _, v := range vars.Vars {
+		if v.Name != "ProjectName" {
+			continue
+		}
+		expected := []string{"required"}
+		if !reflect.DeepEqual(v.Kinds, expected) || !v.Supplied {
+			t.Errorf("Expected ProjectName to be supplied with kinds %v, "+
+				"got %v", expected, v)
+		}
+		return
+	}
+	t.Errorf("Expected ProjectName to be listed, got '%s'", output)
+}
+
+func TestRecipeListJSONFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bake-config")
+	if err != nil {
+		t.Fatalf("Couldn't create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Recipes are installed to the user's configuration directory.
+	config := os.Getenv("XDG_CONFIG_HOME")
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	defer os.Setenv("XDG_CONFIG_HOME", config)
+
+	cmd, output, errput := runBake(t, "recipe", "list", "--format", "json")
+	if !cmd.ProcessState.Success() {
+		t.Fatalf("bake did not exit successfully: %s", errput)
+	}
+	if strings.TrimSpace(output) != `{
+  "recipes": []
+}` {
+		t.Errorf("Expected no recipes, got '%s'", output)
+	}
+
+	src := path.Join(os.Getenv("BAKE"), "recipes", "go")
+	cmd, _, errput = runBake(t, "recipe", "install", "-l", "copy", src)
+	if !cmd.ProcessState.Success() {
+		t.Fatalf("bake did not exit successfully: %s", errput)
+	}
+
+	cmd, output, errput = runBake(t, "recipe", "list", "--format", "json")
+	if !cmd.ProcessState.Success() {
+		t.Fatalf("bake did not exit successfully: %s", errput)
+	}
+
+	var recipes struct {
+		Recipes []struct {
+			Lang    string `json:"language"`
+			Version string `json:"version"`
+			Source  string `json:"source"`
+		} `json:"recipes"`
+	}
+	if err := json.Unmarshal([]byte(output), &recipes); err != nil {
+		t.Fatalf("Couldn't decode '%s': %v", output, err)
+	}
+	if len(recipes.Recipes) != 1 || recipes.Recipes[0].Lang != "copy" ||
+		recipes.Recipes[0].Version == "" {
+
+		t.Fatalf("Expected the 'copy' recipe, got '%s'", output)
+	}
+}
+
+func TestRecipeInstallLongOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bake-config")
+	if err != nil {
+		t.Fatalf("Couldn't create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := os.Getenv("XDG_CONFIG_HOME")
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	defer os.Setenv("XDG_CONFIG_HOME", config)
+
+	src := path.Join(os.Getenv("BAKE"), "recipes", "go")
+	cmd, _, errput := runBake(t, "recipe", "install", "--language", "copy",
+		src)
+	if !cmd.ProcessState.Success() {
+		t.Fatalf("bake did not exit successfully: %s", errput)
+	}
+
+	cmd, _, _ = runBake(t, "recipe", "install", "--language", "copy", src)
+	if cmd.ProcessState.Success() {
+		t.Fatalf("bake exited successfully, expected failure")
+	}
+
+	cmd, _, errput = runBake(t, "recipe", "install", "--language", "copy",
+		"--force", src)
+	if !cmd.ProcessState.Success() {
+		t.Fatalf("bake did not exit successfully: %s", errput)
+	}
+}
+
+func TestCompletionCmd(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		cmd, output, errput := runBake(t, "completion", shell)
+
+		if !cmd.ProcessState.Success() {
+			t.Fatalf("bake did not exit successfully: %s", errput)
+		}
+
+		if !strings.Contains(output, "bake list types") {
+			t.Errorf("Expected %s completion to list types, got '%s'", shell,
+				output)
+		}
+	}
+
+	// zsh's completion system is only initialised if it isn't already.
+	_, output, _ := runBake(t, "completion", "zsh")
+	if !strings.Contains(output, "if ! (( $+functions[compdef] )); then") {
+		t.Errorf("Expected zsh completion to check for compdef, got '%s'",
+			output)
+	}
+
+	cmd, _, errput := runBake(t, "completion", "csh")
+	if cmd.ProcessState.Success() {
+		t.Fatalf("bake exited successfully, expected failure")
+	}
+	if len(errput) == 0 {
+		t.Fatalf("Expected error, stderr was empty")
+	}
+}
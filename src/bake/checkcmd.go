@@ -0,0 +1,67 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bake/proj"
+	"bake/recipe"
+	"bake/template"
+	"fmt"
+	"os"
+)
+
+func checkTemplatesUsage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n"+
+		"  %s check-templates lang\n", os.Args[0])
+}
+
+// runCheckTemplatesCmd runs the `bake check-templates` command with the
+// arguments following `check-templates` and exits. It prints every error in
+// the include files and templates of the recipe for a language, and fails if
+// there are any.
+func runCheckTemplatesCmd(args []string) {
+	if len(args) != 1 {
+		checkTemplatesUsage()
+		os.Exit(2)
+	}
+	validateLang(args[0])
+
+	r, err := recipe.For(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+
+	errs := proj.CheckAll(r, proj.CheckVars)
+	for _, err := range errs {
+		printErr(err)
+	}
+
+	if len(errs) > 0 {
+		fmt.Fprintf(os.Stderr, "%d errors in the %s recipe\n", len(errs),
+			args[0])
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// printErr prints `err` to stderr. Each error in a template is printed on its
+// own line, followed by the line of the template that it's on, if known, with
+// a caret under the position of the error.
+func printErr(err error) {
+	switch err := err.(type) {
+	case template.ErrorList:
+		for _, e := range err {
+			printErr(e)
+		}
+	case *template.Error:
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		if c := err.Caret(); c != "" {
+			fmt.Fprintf(os.Stderr, "%s\n", c)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// completionScripts maps the shells that bake can print completion scripts for
+// to the scripts. The scripts complete languages and types by running
+// `bake list`, so they follow the installed recipes.
+var completionScripts = map[string]string{
+	"bash": bashCompletion,
+	"zsh":  zshCompletion,
+	"fish": fishCompletion,
+}
+
+func completionUsage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n"+
+		"  %s completion bash|zsh|fish\n", os.Args[0])
+}
+
+// runCompletionCmd runs the `bake completion` command with the arguments
+// following `completion` and exits. It prints the completion script for the
+// given shell.
+func runCompletionCmd(args []string) {
+	if len(args) != 1 {
+		completionUsage()
+		os.Exit(2)
+	}
+
+	script, ok := completionScripts[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "'%s' is not a supported shell\n", args[0])
+		completionUsage()
+		os.Exit(2)
+	}
+
+	fmt.Print(script)
+	os.Exit(0)
+}
+
+const bashCompletion = `# Completes bake in bash.
+# Load it with: source <(bake completion bash)
+
+# _bake_lang prints the language given on the command line being completed.
+_bake_lang() {
+	local i word
+	for ((i = 1; i < COMP_CWORD; i++)); do
+		word="${COMP_WORDS[i]}"
+		case "$word" in
+		-l|--language|-language)
+			# bash splits "--language=go" into three words.
+			if [ "${COMP_WORDS[i+1]}" = "=" ]; then
+				echo "${COMP_WORDS[i+2]}"
+			else
+				echo "${COMP_WORDS[i+1]}"
+			fi
+			return
+			;;
+		--language=*|-language=*)
+			echo "${word#*=}"
+			return
+			;;
+		esac
+	done
+	if [ "${COMP_WORDS[1]}" = vars ] && [ "$COMP_CWORD" -gt 2 ]; then
+		echo "${COMP_WORDS[2]}"
+	fi
+}
+
+_bake_langs() {
+	bake list langs 2>/dev/null | cut -f1
+}
+
+_bake() {
+	local cur prev lang words
+	cur="${COMP_WORDS[COMP_CWORD]}"
+	prev="${COMP_WORDS[COMP_CWORD-1]}"
+	COMPREPLY=()
+	if [ "$cur" = "=" ]; then
+		cur=""
+	elif [ "$prev" = "=" ]; then
+		prev="${COMP_WORDS[COMP_CWORD-2]}"
+	fi
+
+	case "$prev" in
+	-l|--language|-language|-T|--types|-types)
+		COMPREPLY=($(compgen -W "$(_bake_langs)" -- "$cur"))
+		return
+		;;
+	-t|--type|-type)
+		# Only the type after the last comma is completed, so that a
+		# list of types can be built up.
+		lang="$(_bake_lang)"
+		if [ -n "$lang" ]; then
+			words="$(bake list types "$lang" 2>/dev/null | cut -f1)"
+			COMPREPLY=($(compgen -P "${cur%"${cur##*,}"}" -W "$words" \
+				-- "${cur##*,}"))
+		fi
+		return
+		;;
+	--format|-format)
+		COMPREPLY=($(compgen -W "text json" -- "$cur"))
+		return
+		;;
+	-o|--owner|-owner|-n|--name|-name|-e|--email|-email)
+		return
+		;;
+	esac
+
+	if [ "$COMP_CWORD" -eq 1 ]; then
+		words="new add list vars test recipe check-templates completion"
+	else
+		case "${COMP_WORDS[1]}" in
+		list)
+			if [ "$COMP_CWORD" -eq 2 ]; then
+				words="langs types licenses"
+			elif [ "$COMP_CWORD" -eq 3 ] && [ "$prev" = types ]; then
+				words="$(_bake_langs)"
+			fi
+			;;
+		vars|check-templates)
+			if [ "$COMP_CWORD" -eq 2 ]; then
+				words="$(_bake_langs)"
+			fi
+			;;
+		test)
+			words="$(_bake_langs)"
+			;;
+		recipe)
+			if [ "$COMP_CWORD" -eq 2 ]; then
+				words="install list remove"
+			fi
+			;;
+		completion)
+			if [ "$COMP_CWORD" -eq 2 ]; then
+				words="bash zsh fish"
+			fi
+			;;
+		esac
+	fi
+
+	case "$cur" in
+	-*)
+		words="-l --language -o --owner -n --name -t --type -e --email"
+		words="$words -v --verbose -L --languages -T --types --format"
+		;;
+	esac
+	COMPREPLY=($(compgen -W "$words" -- "$cur"))
+}
+
+complete -F _bake bake
+`
+
+// zshCompletion loads the bash completion script into zsh, which can run bash
+// completion functions. The completion system is only initialised if the
+// user's configuration hasn't already done so, as running compinit again is
+// slow and discards the completions defined before it.
+const zshCompletion = `# Completes bake in zsh.
+# Load it with: source <(bake completion zsh)
+
+if ! (( $+functions[compdef] )); then
+	autoload -U +X compinit && compinit
+fi
+autoload -U +X bashcompinit && bashcompinit
+
+` + bashCompletion
+
+const fishCompletion = `# Completes bake in fish.
+# Load it with: bake completion fish | source
+
+# __bake_lang prints the language given on the command line being completed.
+function __bake_lang
+	set -l tokens (commandline -opc)
+	for i in (seq 2 (count $tokens))
+		switch $tokens[$i]
+			case -l --language -language
+				if test $i -lt (count $tokens)
+					echo $tokens[(math $i + 1)]
+				end
+				return
+			case '--language=*' '-language=*'
+				string replace -r '^[^=]*=' '' -- $tokens[$i]
+				return
+		end
+	end
+	if test (count $tokens) -ge 3; and test $tokens[2] = vars
+		echo $tokens[3]
+	end
+end
+
+function __bake_langs
+	bake list langs 2>/dev/null | cut -f1
+end
+
+# __bake_types prints the types of the language being completed, each after
+# the types that are already listed before the last comma.
+function __bake_types
+	set -l lang (__bake_lang)
+	test -n "$lang"; or return
+	set -l listed (string match -r '^.*,' -- (commandline -ct))
+	for t in (bake list types $lang 2>/dev/null | cut -f1)
+		echo $listed$t
+	end
+end
+
+set -l cmds new add list vars test recipe check-templates completion
+
+complete -c bake -f
+complete -c bake -n "not __fish_seen_subcommand_from $cmds" -a "$cmds"
+
+complete -c bake -s l -l language -x -a '(__bake_langs)'
+complete -c bake -s o -l owner -x
+complete -c bake -s n -l name -x
+complete -c bake -s t -l type -x -a '(__bake_types)'
+complete -c bake -s e -l email -x
+complete -c bake -s v -l verbose
+complete -c bake -s L -l languages
+complete -c bake -s T -l types -x -a '(__bake_langs)'
+complete -c bake -l format -x -a 'text json'
+
+# Each subcommand completes its own subcommands or arguments.
+set -l lists langs types licenses
+complete -c bake -n "__fish_seen_subcommand_from list; \
+	and not __fish_seen_subcommand_from $lists" -a "$lists"
+complete -c bake -n '__fish_seen_subcommand_from types' -a '(__bake_langs)'
+complete -c bake -n '__fish_seen_subcommand_from vars check-templates test' \
+	-a '(__bake_langs)'
+set -l recipes install list remove
+complete -c bake -n "__fish_seen_subcommand_from recipe; \
+	and not __fish_seen_subcommand_from $recipes" -a "$recipes"
+complete -c bake -n '__fish_seen_subcommand_from completion' -a 'bash zsh fish'
+`
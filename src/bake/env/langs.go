@@ -4,26 +4,86 @@
 
 package env
 
-import "io/ioutil"
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+)
+
+const (
+	typesDir = "types" // The recipe directory containing include files
+)
+
+// A Lang is a language supported by bake, along with the sources on the search
+// path that provide a recipe for it, in order of precedence.
+type Lang struct {
+	Name    string
+	Sources []Source
+}
 
 // SupportedLangs returns the languages supported by bake
 func SupportedLangs() ([]string, error) {
-	templatesPath, err := TemplatesPath()
+	langs, err := Langs()
 	if err != nil {
 		return nil, err
 	}
 
-	files, err := ioutil.ReadDir(templatesPath)
+	names := make([]string, len(langs))
+	for i, lang := range langs {
+		names[i] = lang.Name
+	}
+
+	return names, nil
+}
+
+// Langs returns the languages supported by bake, sorted by name.
+func Langs() ([]Lang, error) {
+	srcs, err := SearchPath()
 	if err != nil {
 		return nil, err
 	}
 
-	langs := make([]string, 0, len(files))
-	for _, file := range files {
-		if file.IsDir() {
-			langs = append(langs, file.Name())
+	found := map[string][]Source{}
+	for _, src := range srcs {
+		files, err := ioutil.ReadDir(src.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			typesPath := path.Join(src.Path, file.Name(), typesDir)
+			if fi, err := os.Stat(typesPath); err == nil && fi.IsDir() {
+				found[file.Name()] = append(found[file.Name()], src)
+			}
 		}
 	}
 
+	// Recipes using the split layout keep their templates in a separate
+	// tree, which is optional.
+	if templatesPath, err := TemplatesPath(); err == nil {
+		files, err := ioutil.ReadDir(templatesPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if file.IsDir() {
+				found[file.Name()] = append(found[file.Name()],
+					Source{BakeSource, templatesPath})
+			}
+		}
+	}
+
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	langs := make([]Lang, len(names))
+	for i, name := range names {
+		langs[i] = Lang{name, found[name]}
+	}
+
 	return langs, nil
 }
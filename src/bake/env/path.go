@@ -0,0 +1,77 @@
+// Copyright 2012 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package env
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+)
+
+const (
+	pathVar         = "BAKE_PATH"     // Extra directories of bake recipes
+	projRecipesDir  = ".bake-recipes" // Project-local recipes directory
+	userRecipesDir  = "bake/recipes"  // User recipes, in the config dir
+	userRecipesPerm = 0777
+)
+
+// Names of the sources on the search path that aren't named after their path.
+const (
+	ProjectSource = "project"
+	UserSource    = "user"
+	BakeSource    = "bake"
+)
+
+// A Source is a directory of recipes on the recipe search path.
+type Source struct {
+	Name string // Where the source comes from, e.g. "user" or a path
+	Path string // The directory containing the source's recipes
+}
+
+// SearchPath returns the directories that recipes are loaded from, in order of
+// precedence. These are `./.bake-recipes`, followed by each entry of
+// `BAKE_PATH`, the user recipes directory and finally `$BAKE/recipes`. Only
+// existing directories are returned.
+func SearchPath() ([]Source, error) {
+	var srcs []Source
+
+	srcs = appendIfDir(srcs, Source{ProjectSource, projRecipesDir})
+
+	for _, dir := range filepath.SplitList(os.Getenv(pathVar)) {
+		if len(dir) > 0 {
+			srcs = appendIfDir(srcs, Source{dir, dir})
+		}
+	}
+
+	if userPath, err := UserRecipesPath(); err == nil {
+		srcs = appendIfDir(srcs, Source{UserSource, userPath})
+	}
+
+	recipesPath, err := RecipesPath()
+	if err == nil {
+		srcs = append(srcs, Source{BakeSource, recipesPath})
+	} else if len(srcs) == 0 {
+		return nil, err
+	}
+
+	return srcs, nil
+}
+
+func appendIfDir(srcs []Source, src Source) []Source {
+	if fi, err := os.Stat(src.Path); err == nil && fi.IsDir() {
+		srcs = append(srcs, src)
+	}
+	return srcs
+}
+
+// UserRecipesPath returns the directory containing the current user's recipes,
+// which may not exist.
+func UserRecipesPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(configDir, userRecipesDir), nil
+}
@@ -12,14 +12,42 @@ import (
 
 const (
 	bakeVar      = "BAKE"      // The name of the bake environment variable
-	templatesDir = "templates" // The directory containing bake templates
+	recipesDir   = "recipes"   // The directory containing bake recipes
+	templatesDir = "templates" // The directory containing split templates
 )
 
-func TemplatesPath() (string, error) {
+// BakePath returns the root of the bake installation.
+func BakePath() (string, error) {
 	bakeDir := os.Getenv(bakeVar)
 	if len(bakeDir) == 0 {
 		return "", errors.New("bake environment variable not set")
 	}
+	return bakeDir, nil
+}
+
+// RecipesPath returns the directory containing the bake recipes.
+func RecipesPath() (string, error) {
+	bakeDir, err := BakePath()
+	if err != nil {
+		return "", err
+	}
+
+	recipesPath := path.Join(bakeDir, recipesDir)
+	if _, err := os.Stat(recipesPath); os.IsNotExist(err) {
+		return "", errors.New("bake root doesn't contain recipes")
+	}
+
+	return recipesPath, nil
+}
+
+// TemplatesPath returns the directory containing templates for recipes that
+// use the split layout, where templates and include files are kept apart from
+// the recipe's tests.
+func TemplatesPath() (string, error) {
+	bakeDir, err := BakePath()
+	if err != nil {
+		return "", err
+	}
 
 	templatesPath := path.Join(bakeDir, templatesDir)
 	if _, err := os.Stat(templatesPath); os.IsNotExist(err) {
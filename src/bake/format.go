@@ -0,0 +1,75 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// The formats that bake can print listings and generation results in.
+const (
+	textFormat = "text"
+	jsonFormat = "json"
+)
+
+// format is the format that listings and generation results are printed in.
+var format = textFormat
+
+// addFormatFlag adds the `--format` option to `flags`.
+func addFormatFlag(flags *flag.FlagSet) {
+	flags.StringVar(&format, "format", textFormat,
+		"Output format, text or json")
+}
+
+// validateFormat exits if `format` isn't a known format.
+func validateFormat() {
+	if format != textFormat && format != jsonFormat {
+		fmt.Fprintf(os.Stderr, "'%s' is not a valid format\n", format)
+		os.Exit(2)
+	}
+}
+
+// printJSON writes `v` to `w` as an indented JSON document.
+func printJSON(w io.Writer, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// A langDoc describes a supported language in JSON.
+type langDoc struct {
+	Name    string   `json:"name"`
+	Sources []string `json:"sources"`
+}
+
+// A varDoc describes a variable of a project's templates in JSON.
+type varDoc struct {
+	Name     string   `json:"name"`
+	Kinds    []string `json:"kinds"`
+	Supplied bool     `json:"supplied"`
+}
+
+// A recipeDoc describes an installed recipe in JSON.
+type recipeDoc struct {
+	Lang    string `json:"language"`
+	Version string `json:"version"`
+	Source  string `json:"source"`
+}
+
+// A typeDoc describes a type of a language in JSON.
+type typeDoc struct {
+	Name      string   `json:"name"`
+	Source    string   `json:"source"`
+	Descr     string   `json:"description"`
+	Requires  []string `json:"requires"`
+	Conflicts []string `json:"conflicts"`
+}
@@ -0,0 +1,89 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// listCmds maps the subcommands of `bake list` to the functions that run them
+// with the remaining arguments.
+var listCmds = map[string]func(args []string) error{
+	"langs":    listLangs,
+	"types":    listTypes,
+	"licenses": listLicenses,
+}
+
+func listUsage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n"+
+		"  %[1]s list langs [--format f]\n"+
+		"  %[1]s list types lang [--format f]\n"+
+		"  %[1]s list licenses\n", os.Args[0])
+}
+
+// runListCmd runs the `bake list` command with the arguments following `list`
+// and exits.
+func runListCmd(args []string) {
+	if len(args) == 0 {
+		listUsage()
+		os.Exit(2)
+	}
+
+	cmd, ok := listCmds[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "'%s' is not a list command\n", args[0])
+		listUsage()
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	flags.Usage = listUsage
+	addFormatFlag(flags)
+	flags.Parse(args[1:])
+
+	// The options may also follow the language of `list types`.
+	rest := flags.Args()
+	if len(rest) > 0 {
+		flags.Parse(rest[1:])
+		rest = append(rest[:1:1], flags.Args()...)
+	}
+	validateFormat()
+
+	if err := cmd(rest); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	os.Exit(0)
+}
+
+func listLangs(args []string) error {
+	if len(args) != 0 {
+		listUsage()
+		os.Exit(2)
+	}
+	return printLangs(format)
+}
+
+func listTypes(args []string) error {
+	if len(args) != 1 {
+		listUsage()
+		os.Exit(2)
+	}
+	validateLang(args[0])
+	printTypesFor(os.Stdout, args[0], format)
+	return nil
+}
+
+// listLicenses fails, as bake can't generate licenses yet.
+func listLicenses(args []string) error {
+	if len(args) != 0 {
+		listUsage()
+		os.Exit(2)
+	}
+	return errors.New("No licenses are supported yet")
+}
@@ -0,0 +1,132 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package proj
+
+import (
+	"bake/recipe"
+	"bytes"
+	"fmt"
+	"os"
+)
+
+// AddTypes adds the types `ts` to `p`, which was generated to `dest` with the
+// types that it has, and generates the files and directories that the new types
+// contribute. The files that `p` had already aren't changed or regenerated, but
+// each is expanded with the types that `p` had and with its new types, and is
+// reported in the results as conflicted if the expansions differ, such as when
+// a new type enables a conditional section of a template, and the file doesn't
+// match the new expansion already. Files that the new types don't change are
+// skipped, even if they've been edited since they were generated. The settings
+// of `p` are recorded with its new types.
+func (p *Project) AddTypes(dest string, ts []string) error {
+	r, err := recipe.For(p.lang)
+	if err != nil {
+		return err
+	}
+
+	old, err := p.entries(r, dest)
+	if err != nil {
+		return err
+	}
+	had := make(map[string]entry, len(old))
+	for _, e := range old {
+		had[e.tgt] = e
+	}
+
+	for _, t := range ts {
+		if !p.IsOfType(t) {
+			p.types = append(p.types, t)
+			p.dict.Set(t, "")
+		}
+	}
+	entries, err := p.entries(r, dest)
+	if err != nil {
+		return err
+	}
+
+	p.results = nil
+	for _, e := range entries {
+		prev, ok := had[e.tgt]
+		if !ok {
+			err = p.gen(e)
+		} else if e.tmpl == nil || bytes.Equal(prev.conts, e.conts) {
+			if p.verbose {
+				fmt.Fprintf(p.out, "'%s' isn't changed by the new types, "+
+					"skipping...\n", e.tgt)
+			}
+			p.addResult(e, Skipped)
+		} else {
+			var status string
+			if status, err = p.compareFile(e); err == nil {
+				p.addResult(e, status)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return p.writeSettings(entries)
+}
+
+// InferTypes returns the types of the project in the language `lg` with the
+// variables `vs` that was generated to `dest`, for a project whose settings
+// weren't recorded. A type is taken to have been generated if it adds at least
+// one file or directory to those of the base type, and the project has all of
+// them.
+func InferTypes(lg string, vs map[string]string, dest string) ([]string,
+	error) {
+
+	r, err := recipe.For(lg)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := New(lg, nil, false, vs)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := base.entries(r, dest)
+	if err != nil {
+		return nil, err
+	}
+	inBase := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		inBase[e.tgt] = true
+	}
+
+	names, err := r.Types()
+	if err != nil {
+		return nil, err
+	}
+
+	var types []string
+	for _, t := range names {
+		p, err := New(lg, []string{t}, false, vs)
+		if err != nil {
+			return nil, err
+		}
+		entries, err := p.entries(r, dest)
+		if err != nil {
+			return nil, err
+		}
+
+		added, found := 0, true
+		for _, e := range entries {
+			if inBase[e.tgt] {
+				continue
+			}
+			added++
+			if _, err := os.Stat(e.tgt); err != nil {
+				found = false
+				break
+			}
+		}
+		if added > 0 && found {
+			types = append(types, t)
+		}
+	}
+	return types, nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package proj
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var addRecipe = map[string]string{
+	"types/base":                       "Base\nMakefile\n",
+	"types/bin":                        "Executable\nmain\n",
+	"types/make":                       "Build\nbuild\n",
+	"templates/{ProjectName}/Makefile": "all:{?bin} main{?}\n",
+	"templates/{ProjectName}/main":     "{ProjectName}\n",
+	"templates/{ProjectName}/build":    "make\n",
+}
+
+func TestAddTypes(t *testing.T) {
+	root := tempRecipe(t, addRecipe)
+	defer os.RemoveAll(root)
+
+	vars := map[string]string{"ProjectName": "Proj"}
+	p := newProj(t, []string{"make"}, vars)
+	p.SetOutput(ioutil.Discard)
+	if err := p.GenTo(root); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Edits that don't depend on the new types aren't reported.
+	build := path.Join(root, "Proj/build")
+	if err := ioutil.WriteFile(build, []byte("edited\n"), 0666); err != nil {
+		t.Fatalf("couldn't write 'Proj/build': %v", err)
+	}
+
+	p = newProj(t, []string{"make"}, vars)
+	p.SetOutput(ioutil.Discard)
+	if err := p.AddTypes(root, []string{"bin"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []Result{
+		{path.Join(root, "Proj"), "{ProjectName}", true, Skipped},
+		{path.Join(root, "Proj/build"), "{ProjectName}/build", false,
+			Skipped},
+		{path.Join(root, "Proj/main"), "{ProjectName}/main", false, Created},
+		{path.Join(root, "Proj/Makefile"), "{ProjectName}/Makefile", false,
+			Conflicted},
+	}
+	if !reflect.DeepEqual(p.Results(), expected) {
+		t.Errorf("expected results %v, got %v", expected, p.Results())
+	}
+
+	src, err := ioutil.ReadFile(path.Join(root, "Proj/Makefile"))
+	if err != nil {
+		t.Fatalf("couldn't read 'Proj/Makefile': %v", err)
+	} else if string(src) != "all:\n" {
+		t.Errorf("expected 'Proj/Makefile' to be unchanged, got '%s'", src)
+	}
+
+	s, err := ReadSettings(path.Join(root, "Proj", SettingsFile))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectStrs(t, "types", []string{"make", "bin"}, s.Types)
+}
+
+func TestGenToSettings(t *testing.T) {
+	root := tempRecipe(t, map[string]string{
+		"types/base":                     "Base\nREADME\nrun.sh\n",
+		"templates/{ProjectName}/README": "{ProjectName}\n",
+		"templates/{ProjectName}/run.sh": "echo '{ProjectName}'\n",
+	})
+	defer os.RemoveAll(root)
+
+	vars := map[string]string{"ProjectName": "Bob's"}
+	p := newProj(t, nil, vars)
+	p.SetOutput(ioutil.Discard)
+	if err := p.GenTo(root); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	s, err := ReadSettings(path.Join(root, "Bob's", SettingsFile))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	} else if !reflect.DeepEqual(s.Vars, vars) {
+		t.Errorf("expected variables %v, got %v", vars, s.Vars)
+	}
+}
+
+func TestSettings(t *testing.T) {
+	s := &Settings{"go", []string{"bin", "make"},
+		map[string]string{"Owner": "Me", "ProjectName": "Proj"}}
+	str := s.String()
+
+	expected := "lang: go\ntypes: bin, make\nOwner = Me\nProjectName = Proj\n"
+	if str != expected {
+		t.Errorf("expected '%s', got '%s'", expected, str)
+	}
+
+	read, err := readSettings(bufio.NewScanner(strings.NewReader(str)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	} else if !reflect.DeepEqual(read, s) {
+		t.Errorf("expected %v, got %v", s, read)
+	}
+
+	bad := []string{"types: bin\n", "lang: go\nOwner: Me\n"}
+	for _, src := range bad {
+		_, err := readSettings(bufio.NewScanner(strings.NewReader(src)))
+		if err == nil {
+			t.Errorf("expected error reading '%s', got none", src)
+		}
+	}
+}
+
+func TestInferTypes(t *testing.T) {
+	root := tempRecipe(t, addRecipe)
+	defer os.RemoveAll(root)
+
+	vars := map[string]string{"ProjectName": "Proj"}
+	p := newProj(t, []string{"make"}, vars)
+	p.SetOutput(ioutil.Discard)
+	if err := p.GenTo(root); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.Remove(path.Join(root, "Proj", SettingsFile)); err != nil {
+		t.Fatalf("couldn't remove the settings: %v", err)
+	}
+
+	ts, err := InferTypes("x", vars, root)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectStrs(t, "types", []string{"make"}, ts)
+}
@@ -0,0 +1,163 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package proj
+
+import (
+	"bake/recipe"
+	"bake/template"
+	"fmt"
+	"fs"
+	"io/ioutil"
+	"path"
+)
+
+// CheckVars are placeholder values of the variables that bake supplies, which
+// are used to expand templates while checking a recipe.
+var CheckVars = map[string]string{
+	"ProjectName":      "Project",
+	"ProjectNameLower": "project",
+	"Owner":            "Owner",
+	"Email":            "owner@example.com",
+	"Year":             "2014",
+}
+
+// Check validates the recipe `r` by parsing the include file of every type and
+// expanding every template that they list, along with the partials that they
+// include, using a dictionary made of `vars` and every type in `r`. Nothing is
+// written. The first error that's found is returned.
+func Check(r recipe.Recipe, vars map[string]string) error {
+	if errs := CheckAll(r, vars); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// CheckAll validates `r` like Check, but carries on past errors, and returns
+// every error that it finds. Errors in templates are returned as
+// *template.Error values.
+func CheckAll(r recipe.Recipe, vars map[string]string) []error {
+	types, err := r.Types()
+	if err != nil {
+		return []error{err}
+	}
+
+	d := template.NewDict(vars)
+	for _, t := range types {
+		d.Set(t, "")
+	}
+	d.SetList(TypesVar, types)
+	d.SetPartialLoader(r.Partial)
+
+	c := &checker{r: r, d: d, seen: map[string]bool{}}
+	if err = deriveVars(r, d); err != nil {
+		c.add(err)
+	}
+	if delims, err := r.Delims(); err != nil {
+		c.add(err)
+	} else if err = d.SetDelims(delims); err != nil {
+		c.add(err)
+	}
+
+	for _, t := range append(types, recipe.BaseType) {
+		fpath, err := r.TypeFile(t)
+		if err != nil {
+			c.add(err)
+			continue
+		}
+
+		if err = checkHeader(r, fpath); err != nil {
+			c.add(err)
+			continue
+		}
+
+		incls, err := ParseInclFiles(fpath)
+		if err != nil {
+			c.add(fmt.Errorf("%s: %v", fpath, err))
+			continue
+		}
+
+		c.checkNode(fs.NewDir("{ProjectName}", incls.Children()...), "")
+	}
+
+	return c.errs
+}
+
+// A checker collects the errors found while checking a recipe.
+type checker struct {
+	r    recipe.Recipe
+	d    *template.Dict
+	errs []error
+
+	// The messages of the errors found so far, as templates that are listed
+	// by several include files are checked once for each.
+	seen map[string]bool
+}
+
+// add records `err`, or each of its errors if it's a template.ErrorList.
+func (c *checker) add(err error) {
+	if errs, ok := err.(template.ErrorList); ok {
+		for _, e := range errs {
+			c.add(e)
+		}
+		return
+	}
+
+	if !c.seen[err.Error()] {
+		c.seen[err.Error()] = true
+		c.errs = append(c.errs, err)
+	}
+}
+
+// checkHeader checks that the types listed in the header of the include file at
+// `fpath` exist in `r`.
+func checkHeader(r recipe.Recipe, fpath string) error {
+	h, err := recipe.ParseInclHeader(fpath)
+	if err != nil {
+		return fmt.Errorf("%s: %v", fpath, err)
+	}
+
+	for _, t := range append(h.Requires, h.Conflicts...) {
+		if _, err = r.TypeFile(t); err != nil {
+			return fmt.Errorf("%s: %v", fpath, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *checker) checkNode(n *fs.Node, srcDir string) {
+	src := path.Join(srcDir, n.Name())
+	if _, err := expandName(c.d, n.Name()); err != nil {
+		c.add(err)
+	}
+
+	if n.IsDir() {
+		for _, child := range n.Children() {
+			c.checkNode(child, src)
+		}
+		return
+	}
+
+	fpath, err := c.r.Template(src)
+	if err != nil {
+		c.add(err)
+		return
+	}
+
+	tmpl, err := template.ParseFileWith(fpath, c.d.Delims())
+	if err != nil {
+		c.add(err)
+		return
+	}
+
+	if err = c.d.SetEscaping(template.EscapingFor(src)); err != nil {
+		c.add(err)
+		return
+	}
+	defer c.d.SetEscaping("")
+	if err = tmpl.Execute(c.d, ioutil.Discard); err != nil {
+		c.add(err)
+	}
+}
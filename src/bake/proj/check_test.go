@@ -0,0 +1,59 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package proj
+
+import (
+	"bake/recipe"
+	"bake/template"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCheckAll(t *testing.T) {
+	root := tempRecipe(t, map[string]string{
+		"types/base":                "Base\na\nb\n",
+		"types/bin":                 "Executable\na\nmissing\n",
+		"types/lib":                 "Library\nrequires: nope\n",
+		"templates/{ProjectName}/a": "{Owner|nope}\n{?bin}{X}{?}\n",
+		"templates/{ProjectName}/b": "{Owner}\n{Y}\n",
+	})
+	defer os.RemoveAll(root)
+
+	r, err := recipe.For("x")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	errs := CheckAll(r, CheckVars)
+	expected := []string{
+		"[1:",
+		"[2:",
+		"missing",
+		"nope",
+		"[2:",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %d: %v", len(expected), len(errs),
+			errs)
+	}
+	for i, err := range errs {
+		if !strings.Contains(err.Error(), expected[i]) {
+			t.Errorf("expected error %d to contain '%s', got: %v", i,
+				expected[i], err)
+		}
+	}
+
+	if e, ok := errs[0].(*template.Error); !ok {
+		t.Errorf("expected a template error, got %T", errs[0])
+	} else if e.Snippet != "{Owner|nope}" {
+		t.Errorf("expected snippet '{Owner|nope}', got '%s'", e.Snippet)
+	}
+
+	err = Check(r, CheckVars)
+	if err == nil || err.Error() != errs[0].Error() {
+		t.Errorf("expected the first error from Check, got: %v", err)
+	}
+}
@@ -5,119 +5,321 @@
 package proj
 
 import (
-	"bake/env"
+	"bake/recipe"
+	"bake/template"
 	"bufio"
+	"bytes"
 	"fmt"
 	"fs"
 	"io"
+	"io/ioutil"
 	"os"
 	"path"
+	"strings"
 )
 
+// rootName is the name of the root directory of a project, which the paths in
+// include files are relative to.
+const rootName = "{ProjectName}"
+
+// The statuses of the files and directories of a project after it's generated.
 const (
-	baseInclFile = "base"
+	Created    = "created"
+	Skipped    = "skipped"    // It existed already, as it would be generated
+	Conflicted = "conflicted" // It existed already, but differs
 )
 
-// GenTo generates the project p to dest.
+// A Result records what generating a file or directory of a project did.
+type Result struct {
+	Path   string `json:"path"`
+	Source string `json:"source"` // The template path that it's generated from
+	Dir    bool   `json:"dir"`
+	Status string `json:"status"`
+}
+
+// Results returns the results of generating the files and directories of `p`,
+// in the order that they were generated.
+func (p *Project) Results() []Result {
+	return p.results
+}
+
+// GenTo generates the project p to dest, and records the settings that it was
+// generated with in its root directory. Files and directories that exist
+// already are left as they are.
 func (p *Project) GenTo(dest string) error {
-	templPath, err := env.TemplatesPath()
+	r, err := recipe.For(p.lang)
+	if err != nil {
+		return err
+	}
+
+	// Every template is parsed, checked and expanded before anything is
+	// generated, so that a broken template or a missing variable doesn't
+	// leave a partially generated project.
+	entries, err := p.entries(r, dest)
 	if err != nil {
 		return err
 	}
 
-	langRoot := path.Join(templPath, p.lang)
+	p.results = nil
+	for _, e := range entries {
+		if err = p.gen(e); err != nil {
+			return err
+		}
+	}
+
+	return p.writeSettings(entries)
+}
 
-	filePaths := joinAll(langRoot, append(p.types, baseInclFile))
-	incls, err := ParseInclFiles(filePaths...)
+// gen generates the file or directory of `e` and records the result.
+func (p *Project) gen(e entry) error {
+	var status string
+	var err error
+	if e.tmpl == nil {
+		status, err = p.genDir(e.tgt)
+	} else {
+		status, err = p.genFile(e)
+	}
 	if err != nil {
 		return err
 	}
-	root := fs.NewDir("{ProjectName}", incls.Children()...)
+	p.addResult(e, status)
+	return nil
+}
+
+func (p *Project) addResult(e entry, status string) {
+	p.results = append(p.results, Result{e.tgt, e.src, e.tmpl == nil, status})
+}
+
+// entries returns the entries for the files and directories of `p` when it's
+// generated to `dest`, after adding the types that the types of `p` require.
+func (p *Project) entries(r recipe.Recipe, dest string) ([]entry, error) {
+	if err := p.addRequiredTypes(r); err != nil {
+		return nil, err
+	}
+	p.dict.SetPartialLoader(r.Partial)
+
+	delims, err := r.Delims()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.dict.SetDelims(delims); err != nil {
+		return nil, err
+	}
+
+	filePaths, err := typeFiles(r, append(p.types, recipe.BaseType))
+	if err != nil {
+		return nil, err
+	}
+	incls, err := ParseInclFilesFor(p.dict, filePaths...)
+	if err != nil {
+		return nil, err
+	}
+	root := fs.NewDir(rootName, incls.Children()...)
+
+	return p.plan(r, fs.NewDir("").AddNode(root), "", dest, nil)
+}
+
+// An entry is a file or directory to be generated.
+type entry struct {
+	src   string
+	tgt   string
+	tmpl  *template.Template // The template of a file, or nil for a directory
+	conts []byte             // The expansion of `tmpl`
+}
+
+// addRequiredTypes adds the types required by the types of `p` to `p`, and
+// fails if any of the resulting types conflict.
+func (p *Project) addRequiredTypes(r recipe.Recipe) error {
+	types, err := requiredTypes(r, p.types)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range types {
+		if p.IsOfType(t) {
+			continue
+		}
+		if p.verbose {
+			fmt.Fprintf(p.out, "Including required type '%s'...\n", t)
+		}
+		p.dict.Set(t, "")
+	}
+	p.types = types
+	p.dict.SetList(TypesVar, types)
+
+	return nil
+}
 
-	return p.genDirConts(fs.NewDir("").AddNode(root), langRoot, "")
+// requiredTypes returns `types` along with the types of `r` that they require.
+func requiredTypes(r recipe.Recipe, types []string) ([]string, error) {
+	return resolveTypes(types, func(t string) (*recipe.InclHeader, error) {
+		fpath, err := r.TypeFile(t)
+		if err != nil {
+			return nil, err
+		}
+		return recipe.ParseInclHeader(fpath)
+	})
 }
 
-func joinAll(dir string, fnames []string) []string {
-	paths := make([]string, len(fnames))
-	copy(paths, fnames)
-	for i, p := range paths {
-		paths[i] = path.Join(dir, p)
+// typeFiles returns the paths of the include files for `types` in `r`.
+func typeFiles(r recipe.Recipe, types []string) ([]string, error) {
+	paths := make([]string, len(types))
+	for i, t := range types {
+		p, err := r.TypeFile(t)
+		if err != nil {
+			return nil, err
+		}
+		paths[i] = p
 	}
-	return paths
+	return paths, nil
 }
 
-func (p *Project) genDirConts(dir *fs.Node, srcDir, tgtDir string) error {
+// plan appends the entries for the contents of `dir` to `entries`, where
+// `srcDir` is the path of `dir` relative to the template root of `r` and
+// `tgtDir` is the directory that they're generated to. Parents come before
+// their contents. Nodes whose names expand to nothing are skipped, along with
+// their contents.
+func (p *Project) plan(r recipe.Recipe, dir *fs.Node, srcDir, tgtDir string,
+	entries []entry) ([]entry, error) {
+
 	for _, node := range dir.Children() {
 		src := path.Join(srcDir, node.Name())
 
-		tgtName, err := p.dict.ExpandStr(node.Name())
+		tgtName, err := expandName(p.dict, node.Name())
 		if err != nil {
-			return err
+			return nil, err
+		} else if tgtName == "" {
+			continue
+		}
+
+		// A name that expands to a path creates the directories that lead
+		// to it.
+		tgt := tgtDir
+		elems := strings.Split(tgtName, "/")
+		for _, e := range elems[:len(elems)-1] {
+			tgt = path.Join(tgt, e)
+			entries = append(entries, entry{src, tgt, nil, nil})
 		}
-		tgt := path.Join(tgtDir, tgtName)
+		tgt = path.Join(tgt, elems[len(elems)-1])
 
-		if node.Children() == nil { // not a dir
-			err = p.genFile(src, tgt)
-		} else if err = p.genDir(tgt); err == nil {
-			err = p.genDirConts(node, src, tgt)
+		if node.IsDir() {
+			entries = append(entries, entry{src, tgt, nil, nil})
+			entries, err = p.plan(r, node, src, tgt, entries)
+			if err != nil {
+				return nil, err
+			}
+			continue
 		}
 
+		fpath, err := r.Template(src)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		tmpl, err := template.ParseFileWith(fpath, p.dict.Delims())
+		if err != nil {
+			return nil, err
 		}
+		if err = tmpl.Check(p.dict); err != nil {
+			return nil, err
+		}
+		conts, err := p.render(tmpl, tgt)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{src, tgt, tmpl, conts})
 	}
 
-	return nil
+	return entries, nil
+}
+
+// render returns the expansion of `tmpl` for the file `tgt`, with values
+// escaped to suit the language of `tgt`.
+func (p *Project) render(tmpl *template.Template, tgt string) ([]byte,
+	error) {
+
+	if err := p.dict.SetEscaping(template.EscapingFor(tgt)); err != nil {
+		return nil, err
+	}
+	defer p.dict.SetEscaping("")
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(p.dict, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
-func (p *Project) genFile(src, tgt string) error {
+// genFile generates the file of `e` and returns its status. A file that exists
+// is skipped, and is conflicted if it differs from the contents of `e`.
+func (p *Project) genFile(e entry) (string, error) {
+	tgt := e.tgt
 	out, err := os.OpenFile(tgt, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
 	if err != nil {
 		if !os.IsExist(err) {
-			return err
+			return "", err
 		}
-		if p.verbose {
-			fmt.Printf("File '%s' exists, skipping...\n", tgt)
-		}
-		return nil
+		return p.compareFile(e)
 	}
 	defer out.Close()
 
-	in, err := os.OpenFile(src, os.O_RDONLY, 0666)
-	if err != nil {
-		return err
-	}
-	defer in.Close()
-
-	err = p.dict.Expand(in, out)
-	if err != nil {
-		return fmt.Errorf("%s%v", src, err)
+	if _, err = out.Write(e.conts); err != nil {
+		return "", err
 	}
 
 	if p.verbose {
-		fmt.Printf("Generated file '%s'\n", tgt)
+		fmt.Fprintf(p.out, "Generated file '%s'\n", tgt)
 	} else {
-		fmt.Printf("%s\n", tgt)
+		fmt.Fprintf(p.out, "%s\n", tgt)
 	}
 
-	return err
+	return Created, nil
 }
 
-func (p *Project) genDir(dir string) error {
+// compareFile returns the status of the file of `e`, which exists, by
+// comparing it with the contents of `e`. A file that has been removed is
+// skipped.
+func (p *Project) compareFile(e entry) (string, error) {
+	tgt := e.tgt
+	actual, err := ioutil.ReadFile(tgt)
+	if os.IsNotExist(err) {
+		if p.verbose {
+			fmt.Fprintf(p.out, "File '%s' is missing, skipping...\n", tgt)
+		}
+		return Skipped, nil
+	} else if err != nil {
+		return "", err
+	}
+
+	if bytes.Equal(actual, e.conts) {
+		if p.verbose {
+			fmt.Fprintf(p.out, "File '%s' exists, skipping...\n", tgt)
+		}
+		return Skipped, nil
+	}
+	if p.verbose {
+		fmt.Fprintf(p.out, "File '%s' exists but differs, skipping...\n",
+			tgt)
+	}
+	return Conflicted, nil
+}
+
+func (p *Project) genDir(dir string) (string, error) {
 	if err := os.Mkdir(dir, 0777); err != nil {
 		if !os.IsExist(err) {
-			return err
+			return "", err
 		}
 		if p.verbose {
-			fmt.Printf("Directory '%s/' exists, skipping...\n", dir)
+			fmt.Fprintf(p.out, "Directory '%s/' exists, skipping...\n", dir)
 		}
-	} else if p.verbose {
-		fmt.Printf("Created directory '%s/'\n", dir)
-	} else {
-		fmt.Printf("%s/\n", dir)
+		return Skipped, nil
 	}
 
-	return nil
+	if p.verbose {
+		fmt.Fprintf(p.out, "Created directory '%s/'\n", dir)
+	} else {
+		fmt.Fprintf(p.out, "%s/\n", dir)
+	}
+	return Created, nil
 }
 
 func readLines(reader io.Reader) []string {
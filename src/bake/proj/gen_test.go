@@ -0,0 +1,279 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package proj
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+// tempRecipe creates a bake root that only holds a recipe for the language
+// "x", made of `files`, and returns the root.
+func tempRecipe(t *testing.T, files map[string]string) string {
+	root, err := ioutil.TempDir("", "bake")
+	if err != nil {
+		t.Fatalf("couldn't create bake root: %v", err)
+	}
+
+	vars := map[string]string{
+		"BAKE":            root,
+		"BAKE_PATH":       "",
+		"XDG_CONFIG_HOME": path.Join(root, "config"),
+	}
+	for name, val := range vars {
+		if err = os.Setenv(name, val); err != nil {
+			t.Fatalf("couldn't set %s: %v", name, err)
+		}
+	}
+
+	for name, src := range files {
+		p := path.Join(root, "recipes/x", name)
+		if err = os.MkdirAll(path.Dir(p), 0777); err != nil {
+			t.Fatalf("couldn't create '%s': %v", path.Dir(p), err)
+		}
+		if err = ioutil.WriteFile(p, []byte(src), 0666); err != nil {
+			t.Fatalf("couldn't create '%s': %v", name, err)
+		}
+	}
+
+	return root
+}
+
+// newProj returns a project in the language "x" of `types`.
+func newProj(t *testing.T, types []string, vars map[string]string) Project {
+	p, err := New("x", types, false, vars)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return p
+}
+
+func TestGenTo(t *testing.T) {
+	root := tempRecipe(t, map[string]string{
+		"types/base":                "Base\na\n",
+		"types/bin":                 "Executable\nsrc/\n\t{ProjectName|lower}\n",
+		"templates/{ProjectName}/a": "{ProjectName}{?bin} bin{?}\n",
+		"templates/{ProjectName}/src/{ProjectName|lower}": "{>p}",
+		"partials/p": "{Owner}\n",
+	})
+	defer os.RemoveAll(root)
+
+	vars := map[string]string{"ProjectName": "Proj", "Owner": "me"}
+	p := newProj(t, []string{"bin"}, vars)
+	if err := p.GenTo(root); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"Proj/a":        "Proj bin\n",
+		"Proj/src/proj": "me",
+	}
+	for name, conts := range expected {
+		data, err := ioutil.ReadFile(path.Join(root, name))
+		if err != nil {
+			t.Errorf("couldn't read '%s': %v", name, err)
+		} else if string(data) != conts {
+			t.Errorf("expected '%s' to contain '%s', got '%s'",
+				name, conts, data)
+		}
+	}
+}
+
+func TestGenToExcludedContents(t *testing.T) {
+	root := tempRecipe(t, map[string]string{
+		"types/base":                    "Base\ndir/\n\ta {?foo}\n",
+		"templates/{ProjectName}/dir/a": "a\n",
+	})
+	defer os.RemoveAll(root)
+
+	p := newProj(t, nil, map[string]string{"ProjectName": "Proj"})
+	p.SetOutput(ioutil.Discard)
+	if err := p.GenTo(root); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A directory whose contents are all excluded is still a directory.
+	fi, err := os.Stat(path.Join(root, "Proj/dir"))
+	if err != nil {
+		t.Fatalf("couldn't stat 'Proj/dir': %v", err)
+	} else if !fi.IsDir() {
+		t.Errorf("expected 'Proj/dir' to be a directory")
+	}
+	if _, err := os.Stat(path.Join(root, "Proj/dir/a")); err == nil {
+		t.Errorf("expected 'Proj/dir/a' not to be generated")
+	}
+}
+
+func TestGenToDelims(t *testing.T) {
+	root := tempRecipe(t, map[string]string{
+		"delims":                    "<% %>\n",
+		"types/base":                "Base\na\n",
+		"templates/{ProjectName}/a": "{ <%ProjectName%> }\n<%>p%>",
+		"partials/p":                "<%Owner%>\n",
+	})
+	defer os.RemoveAll(root)
+
+	vars := map[string]string{"ProjectName": "Proj", "Owner": "me"}
+	p := newProj(t, nil, vars)
+	if err := p.GenTo(root); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(path.Join(root, "Proj/a"))
+	if err != nil {
+		t.Fatalf("couldn't read 'Proj/a': %v", err)
+	} else if string(data) != "{ Proj }\nme" {
+		t.Errorf("expected '{ Proj }\\nme', got '%s'", data)
+	}
+}
+
+func TestGenToEscaped(t *testing.T) {
+	root := tempRecipe(t, map[string]string{
+		"types/base":                        "Base\nmain.go\nconf.json\nREADME\n",
+		"templates/{ProjectName}/main.go":   "s := \"{Owner}\" // {Owner|raw}\n",
+		"templates/{ProjectName}/conf.json": "{{\"owner\": \"{Owner}\"}}\n",
+		"templates/{ProjectName}/README":    "By {Owner}.\n",
+	})
+	defer os.RemoveAll(root)
+
+	vars := map[string]string{"ProjectName": "Proj", "Owner": `A "B" \ C`}
+	p := newProj(t, nil, vars)
+	if err := p.GenTo(root); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"Proj/main.go":   `s := "A \"B\" \\ C" // A "B" \ C` + "\n",
+		"Proj/conf.json": `{"owner": "A \"B\" \\ C"}` + "\n",
+		"Proj/README":    `By A "B" \ C.` + "\n",
+	}
+	for name, conts := range expected {
+		data, err := ioutil.ReadFile(path.Join(root, name))
+		if err != nil {
+			t.Errorf("couldn't read '%s': %v", name, err)
+		} else if string(data) != conts {
+			t.Errorf("expected '%s' to contain '%s', got '%s'",
+				name, conts, data)
+		}
+	}
+}
+
+func TestGenToPathNames(t *testing.T) {
+	root := tempRecipe(t, map[string]string{
+		"types/base": "Base\n" +
+			"{?bin}main.go{?}\n" +
+			"{?lib}lib{?}/\n" +
+			"\tlib.go\n" +
+			"src/\n" +
+			"\t{Module|path}/\n" +
+			"\t\tmod.go\n",
+		"types/bin": "Executable\n",
+		"templates/{ProjectName}/{?bin}main.go{?}":         "main\n",
+		"templates/{ProjectName}/{?lib}lib{?}/lib.go":      "lib\n",
+		"templates/{ProjectName}/src/{Module|path}/mod.go": "mod\n",
+	})
+	defer os.RemoveAll(root)
+
+	vars := map[string]string{
+		"ProjectName": "Proj",
+		"Module":      "github.com/me/proj",
+	}
+	p := newProj(t, []string{"bin"}, vars)
+	if err := p.GenTo(root); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, name := range []string{
+		"Proj/main.go",
+		"Proj/src/github.com/me/proj/mod.go",
+	} {
+		if _, err := os.Stat(path.Join(root, name)); err != nil {
+			t.Errorf("expected '%s' to be generated: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(path.Join(root, "Proj/lib")); err == nil {
+		t.Errorf("expected the contents of 'lib/' to be skipped")
+	}
+
+	vars["Module"] = "../proj"
+	p = newProj(t, []string{"bin"}, vars)
+	if err := p.GenTo(path.Join(root, "other")); err == nil {
+		t.Errorf("expected error generating a module path of '../proj'")
+	}
+}
+
+func TestGenToBrokenTemplate(t *testing.T) {
+	tests := map[string]string{
+		"syntax":           "{?bin}{Owner|}{?}\n",
+		"filter":           "{?bin}{Owner|nope}{?}\n",
+		"partial":          "{?bin}{>nope}{?}\n",
+		"unknown variable": "{Unknown}\n",
+	}
+
+	for name, src := range tests {
+		root := tempRecipe(t, map[string]string{
+			"types/base":                "Base\na\nb\n",
+			"types/bin":                 "Executable\n",
+			"templates/{ProjectName}/a": "{ProjectName}\n",
+			"templates/{ProjectName}/b": src,
+		})
+
+		vars := map[string]string{"ProjectName": "Proj", "Owner": "me"}
+		p := newProj(t, nil, vars)
+		if err := p.GenTo(root); err == nil {
+			t.Errorf("expected error generating %s error", name)
+		}
+
+		// Nothing is generated if any template is broken.
+		if _, err := os.Stat(path.Join(root, "Proj")); err == nil {
+			t.Errorf("expected nothing to be generated for %s error",
+				name)
+		}
+
+		os.RemoveAll(root)
+	}
+}
+
+func TestGenToResults(t *testing.T) {
+	root := tempRecipe(t, map[string]string{
+		"types/base":                    "Base\na\nb\nsrc/\n\tc\n",
+		"templates/{ProjectName}/a":     "{ProjectName}\n",
+		"templates/{ProjectName}/b":     "{ProjectName}\n",
+		"templates/{ProjectName}/src/c": "c\n",
+	})
+	defer os.RemoveAll(root)
+
+	p := newProj(t, nil, map[string]string{"ProjectName": "Proj"})
+	p.SetOutput(ioutil.Discard)
+	if err := p.GenTo(root); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.Remove(path.Join(root, "Proj/src/c")); err != nil {
+		t.Fatalf("couldn't remove 'Proj/src/c': %v", err)
+	}
+	err := ioutil.WriteFile(path.Join(root, "Proj/b"), []byte("x\n"), 0666)
+	if err != nil {
+		t.Fatalf("couldn't write 'Proj/b': %v", err)
+	}
+
+	if err := p.GenTo(root); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []Result{
+		{path.Join(root, "Proj"), "{ProjectName}", true, Skipped},
+		{path.Join(root, "Proj/a"), "{ProjectName}/a", false, Skipped},
+		{path.Join(root, "Proj/b"), "{ProjectName}/b", false, Conflicted},
+		{path.Join(root, "Proj/src"), "{ProjectName}/src", true, Skipped},
+		{path.Join(root, "Proj/src/c"), "{ProjectName}/src/c", false,
+			Created},
+	}
+	if !reflect.DeepEqual(p.Results(), expected) {
+		t.Errorf("expected results %v, got %v", expected, p.Results())
+	}
+}
@@ -5,6 +5,8 @@
 package proj
 
 import (
+	"bake/recipe"
+	"bake/template"
 	"bufio"
 	"fmt"
 	"fs"
@@ -18,11 +20,29 @@ const (
 	// independent of the actual directory separator used by the runtime
 	// platform.
 	inclDirSep = '/'
+
+	// Begins the condition that may follow the name of an entry in an include
+	// file, which is written as a conditional section would be in a template,
+	// e.g. `LICENSE {?License}`.
+	inclCondStart = " {?"
+	inclCondEnd   = "}"
 )
 
 // Return a filesystem description composed of files described by each include
-// file in `paths`.
+// file in `paths`. Conditional entries are included along with their
+// conditions.
 func ParseInclFiles(paths ...string) (*fs.Node, error) {
+	return parseInclFiles(nil, paths)
+}
+
+// Return a filesystem description composed of files described by each include
+// file in `paths`, excluding conditional entries whose conditions don't hold in
+// `d`.
+func ParseInclFilesFor(d *template.Dict, paths ...string) (*fs.Node, error) {
+	return parseInclFiles(d, paths)
+}
+
+func parseInclFiles(d *template.Dict, paths []string) (*fs.Node, error) {
 	root := fs.NewDir("")
 
 	for _, path := range paths {
@@ -34,12 +54,13 @@ func ParseInclFiles(paths ...string) (*fs.Node, error) {
 
 		in := bufio.NewReader(file)
 
-		// Skip description
-		if _, err = in.ReadString('\n'); err != nil {
+		_, first, err := recipe.ReadInclHeader(in)
+		if err != nil {
 			return nil, err
 		}
 
-		if err = addIncl(root, in); err != nil {
+		r := io.MultiReader(strings.NewReader(first), in)
+		if err = addIncl(root, r, d); err != nil {
 			return nil, err
 		}
 	}
@@ -47,12 +68,19 @@ func ParseInclFiles(paths ...string) (*fs.Node, error) {
 	return root, nil
 }
 
-// Add files described in the `reader` stream to the root node `n`.
-func addIncl(n *fs.Node, reader io.Reader) error {
+// Add files described in the `reader` stream to the root node `n`. If `d` is
+// nil then conditional entries are added along with their conditions,
+// otherwise they're only added if their conditions hold in `d`.
+func addIncl(n *fs.Node, reader io.Reader, d *template.Dict) error {
 	in := bufio.NewReader(reader)
 	nodePath := []*fs.Node{n}
 	enterDir := false
 
+	// The conditions that the entries listed in each directory of
+	// `nodePath` are given, in addition to their own, because the
+	// directory's condition doesn't imply the condition it's listed with.
+	dirConds := []string{""}
+
 	for {
 		line, err := in.ReadString('\n')
 		if err != nil {
@@ -69,23 +97,43 @@ func addIncl(n *fs.Node, reader io.Reader) error {
 			return fmt.Errorf("Bad indentation: '%s'", line)
 		} else {
 			nodePath = nodePath[:lvl+1]
+			dirConds = dirConds[:lvl+1]
 		}
 		curDir := nodePath[len(nodePath)-1]
 		enterDir = false
 
-		name := strings.TrimRight(line, "\n\r")[lvl:]
+		name, cond, ok := splitCond(strings.TrimRight(line, "\n\r")[lvl:])
+		include := true
+		if ok {
+			c, err := template.ParseCond(cond)
+			if err != nil {
+				return fmt.Errorf("Bad condition on %s: %v", name, err)
+			}
+			if d != nil {
+				include = c.Eval(d)
+				cond = ""
+			}
+		}
+		cond = andConds(dirConds[len(dirConds)-1], cond)
+
+		// The contents of excluded directories are still parsed, but are
+		// added to a directory that isn't part of the tree.
+		if !include {
+			curDir = fs.NewDir(curDir.Name())
+		}
+
 		if len(name) == 0 {
 			return fmt.Errorf("Empty name in %s/", curDir.Name())
 		} else if !isValidFsName(name) {
 			return fmt.Errorf("%s is not a valid name", name)
 		} else if isDirName(name) {
-			d := name[:len(name)-1]
-			curDir.AddDir(d)
-			dir, _ := curDir.ChildNamed(d)
+			dir, dirCond := addNode(curDir, fs.NewDir(name[:len(name)-1]),
+				cond)
 			nodePath = append(nodePath, dir)
+			dirConds = append(dirConds, dirCond)
 			enterDir = true
 		} else {
-			curDir.AddFile(name)
+			addNode(curDir, fs.NewFile(name), cond)
 		}
 
 		if err == io.EOF {
@@ -100,6 +148,57 @@ func addIncl(n *fs.Node, reader io.Reader) error {
 	return nil
 }
 
+// Split an include file entry into its name and the condition under which it's
+// included. `ok` is false if the entry is always included.
+func splitCond(entry string) (name, cond string, ok bool) {
+	i := strings.LastIndex(entry, inclCondStart)
+	if i < 0 || !strings.HasSuffix(entry, inclCondEnd) {
+		return entry, "", false
+	}
+	end := len(entry) - len(inclCondEnd)
+	return entry[:i], entry[i+len(inclCondStart) : end], true
+}
+
+// Add `node` to `dir` with the condition `cond`, unless `dir` already has a
+// child with the same name, and return the child. A child that's listed more
+// than once is included if any of its conditions hold. The contents of a
+// directory keep the condition of the listing that they come from, so if the
+// condition of a directory changes, the contents that it already has are given
+// its old condition, and the condition that the contents of this listing must
+// be given is returned.
+func addNode(dir, node *fs.Node, cond string) (*fs.Node, string) {
+	child, exists := dir.ChildNamed(node.Name())
+	if !exists {
+		dir.AddNode(node.SetCond(cond))
+		return node, ""
+	}
+
+	c := child.Cond()
+	if c == cond {
+		return child, ""
+	} else if len(c) == 0 || len(cond) == 0 {
+		child.SetCond("")
+	} else {
+		child.SetCond("(" + c + ")|(" + cond + ")")
+	}
+
+	for _, grandchild := range child.Children() {
+		grandchild.SetCond(andConds(c, grandchild.Cond()))
+	}
+	return child, cond
+}
+
+// andConds returns the condition that holds if both `a` and `b` hold, either
+// of which may be "" to always hold.
+func andConds(a, b string) string {
+	if len(a) == 0 {
+		return b
+	} else if len(b) == 0 {
+		return a
+	}
+	return "(" + a + ")&(" + b + ")"
+}
+
 func isDirName(d string) bool {
 	return d[len(d)-1] == inclDirSep
 }
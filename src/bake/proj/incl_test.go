@@ -5,6 +5,7 @@
 package proj
 
 import (
+	"bake/template"
 	"fs"
 	"io"
 	"strings"
@@ -152,8 +153,12 @@ func TestReadIncl(t *testing.T) {
 }
 
 func parseIncl(reader io.Reader) (*fs.Node, error) {
+	return parseInclFor(reader, nil)
+}
+
+func parseInclFor(reader io.Reader, d *template.Dict) (*fs.Node, error) {
 	n := fs.NewDir("")
-	if err := addIncl(n, reader); err != nil {
+	if err := addIncl(n, reader, d); err != nil {
 		return nil, err
 	}
 	return n, nil
@@ -164,7 +169,7 @@ func equal(n *fs.Node, m *fs.Node) bool {
 		return false
 	}
 
-	if n.IsDir() != m.IsDir() {
+	if n.IsDir() != m.IsDir() || n.Cond() != m.Cond() {
 		return false
 	}
 
@@ -172,6 +177,10 @@ func equal(n *fs.Node, m *fs.Node) bool {
 		return true
 	}
 
+	if len(n.Children()) != len(m.Children()) {
+		return false
+	}
+
 	for _, nChild := range n.Children() {
 		mChild, ok := m.ChildNamed(nChild.Name())
 
@@ -235,7 +244,7 @@ func TestAddIncl(t *testing.T) {
 	)
 
 	for _, source := range sources {
-		if err := addIncl(n, strings.NewReader(source)); err != nil {
+		if err := addIncl(n, strings.NewReader(source), nil); err != nil {
 			t.Errorf("Failed: %v", err)
 		}
 	}
@@ -244,3 +253,142 @@ func TestAddIncl(t *testing.T) {
 		t.Errorf("Expected:\n%s\nGot:\n%s", expect.String(), n.String())
 	}
 }
+
+func expectStrs(t *testing.T, descr string, expected, actual []string) {
+	if strings.Join(expected, ",") != strings.Join(actual, ",") {
+		t.Errorf("Expected %s %v, got %v", descr, expected, actual)
+	}
+}
+
+func TestReadInclCond(t *testing.T) {
+	source := "" +
+		"a {?x}\n" +
+		"b/ {?x|y}\n" +
+		"\tc {?!z}\n" +
+		"d\n"
+
+	expected := fs.NewDir("",
+		fs.NewFile("a").SetCond("x"),
+		fs.NewDir("b",
+			fs.NewFile("c").SetCond("!z"),
+		).SetCond("x|y"),
+		fs.NewFile("d"),
+	)
+	expectIncl(t, source, nil, expected)
+
+	expected = fs.NewDir("",
+		fs.NewDir("b",
+			fs.NewFile("c"),
+		),
+		fs.NewFile("d"),
+	)
+	expectIncl(t, source, map[string]string{"y": ""}, expected)
+
+	expected = fs.NewDir("",
+		fs.NewFile("a"),
+		fs.NewDir("b"),
+		fs.NewFile("d"),
+	)
+	expectIncl(t, source, map[string]string{"x": "", "z": ""}, expected)
+
+	expected = fs.NewDir("",
+		fs.NewFile("d"),
+	)
+	expectIncl(t, source, map[string]string{}, expected)
+}
+
+func TestReadInclCmpCond(t *testing.T) {
+	source := "" +
+		"LICENSE {?License=MIT,BSD}\n" +
+		"go.work {?GoVersion>=1.18}\n"
+
+	expected := fs.NewDir("",
+		fs.NewFile("LICENSE"),
+	)
+	vars := map[string]string{"License": "MIT", "GoVersion": "1.17"}
+	expectIncl(t, source, vars, expected)
+
+	expected = fs.NewDir("",
+		fs.NewFile("go.work"),
+	)
+	vars = map[string]string{"License": "GPL", "GoVersion": "1.21"}
+	expectIncl(t, source, vars, expected)
+}
+
+// expectIncl expects `src` to describe `expected`. Conditional entries are
+// evaluated with `vars`, unless it's nil.
+func expectIncl(t *testing.T, src string, vars map[string]string,
+	expected *fs.Node) {
+
+	var d *template.Dict
+	if vars != nil {
+		d = template.NewDict(vars)
+	}
+
+	result, err := parseInclFor(strings.NewReader(src), d)
+	if err != nil {
+		t.Errorf("Failed: %v", err)
+	} else if !equal(result, expected) {
+		t.Errorf("\nParsed:\n%s\nExpected:\n%s\nGot:\n%s",
+			src, expected.String(), result.String())
+	}
+}
+
+func TestAddInclMergesConds(t *testing.T) {
+	n := fs.NewDir("")
+	sources := []string{
+		"a {?x}\nb {?x}\nc {?x}\n",
+		"a {?y}\nb\nc {?x}\n",
+	}
+	expected := fs.NewDir("",
+		fs.NewFile("a").SetCond("(x)|(y)"),
+		fs.NewFile("b"),
+		fs.NewFile("c").SetCond("x"),
+	)
+
+	for _, source := range sources {
+		if err := addIncl(n, strings.NewReader(source), nil); err != nil {
+			t.Errorf("Failed: %v", err)
+		}
+	}
+
+	if !equal(n, expected) {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, n)
+	}
+}
+
+func TestAddInclMergesDirConds(t *testing.T) {
+	n := fs.NewDir("")
+	sources := []string{
+		"a/ {?x}\n\tb\n\tc {?z}\n",
+		"a/\n\td\n",
+		"e/\n\tf\n",
+		"e/ {?y}\n\tg\n",
+	}
+	expected := fs.NewDir("",
+		fs.NewDir("a",
+			fs.NewFile("b").SetCond("x"),
+			fs.NewFile("c").SetCond("(x)&(z)"),
+			fs.NewFile("d"),
+		),
+		fs.NewDir("e",
+			fs.NewFile("f"),
+			fs.NewFile("g").SetCond("y"),
+		),
+	)
+
+	for _, source := range sources {
+		if err := addIncl(n, strings.NewReader(source), nil); err != nil {
+			t.Errorf("Failed: %v", err)
+		}
+	}
+
+	if !equal(n, expected) {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, n)
+	}
+}
+
+func TestBadInclCond(t *testing.T) {
+	expectFail(t, "a {?x&}\n")
+	expectFail(t, "a {?}\n")
+}
@@ -0,0 +1,96 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package proj
+
+import (
+	"bake/template"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// pathFilter is the filter that lets a file name expand to a path.
+const pathFilter = "path"
+
+// pathSep marks the separators output by the `path` filter while a name is
+// expanded. It can't be part of a file name.
+const pathSep = "\x00"
+
+// reservedNames are the names that can't be given to files on some systems,
+// whatever their extensions.
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// expandName expands the file name `name` with `d`. File names are always
+// expanded with the default delimiters, whatever the delimiters of `d`, as the
+// template root and the names in include files are shared by every recipe.
+//
+// A name may expand to "", in which case the file isn't generated. Otherwise,
+// it must expand to a valid file name, or to a relative path made of valid file
+// names whose separators are all output by the `path` filter.
+func expandName(d *template.Dict, name string) (string, error) {
+	tmpl, err := template.Parse(name, strings.NewReader(name))
+	if err != nil {
+		return "", err
+	}
+
+	// Names aren't escaped, whatever the escaping mode of `d`.
+	mode := d.Escaping()
+	if err = d.SetEscaping(""); err != nil {
+		return "", err
+	}
+	defer d.SetEscaping(mode)
+
+	// Only the separators output by the `path` filter separate the elements
+	// of the name, so they're marked while the name is expanded.
+	if path, ok := d.Filter(pathFilter); ok {
+		d.RegisterFilter(pathFilter, func(s string) string {
+			return strings.Replace(path(s), "/", pathSep, -1)
+		})
+		defer d.RegisterFilter(pathFilter, path)
+	}
+
+	var buf bytes.Buffer
+	if err = tmpl.Execute(d, &buf); err != nil {
+		return "", err
+	}
+	expanded := strings.Replace(buf.String(), pathSep, "/", -1)
+	if expanded == "" {
+		return "", nil
+	}
+
+	for _, e := range strings.Split(buf.String(), pathSep) {
+		if err = validateName(e); err != nil {
+			return "", fmt.Errorf("'%s' expands to '%s': %v", name,
+				expanded, err)
+		}
+	}
+
+	return expanded, nil
+}
+
+// validateName returns an error if `name` can't be used as the name of a file.
+func validateName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("a path can't have empty elements")
+	case name == "." || name == "..":
+		return fmt.Errorf("'%s' can't be used as a file name", name)
+	case strings.ContainsAny(name, "/\\"):
+		return fmt.Errorf("'%s' contains a path separator (use the '%s' "+
+			"filter to create directories)", name, pathFilter)
+	}
+
+	base := strings.ToUpper(strings.SplitN(name, ".", 2)[0])
+	if reservedNames[base] {
+		return fmt.Errorf("'%s' is a reserved file name", name)
+	}
+	return nil
+}
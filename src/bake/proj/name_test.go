@@ -0,0 +1,83 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package proj
+
+import (
+	"bake/template"
+	"testing"
+)
+
+func TestExpandName(t *testing.T) {
+	d := template.NewDict(map[string]string{
+		"Name":   "Proj",
+		"Module": "github.com/me/proj",
+		"Dots":   "..",
+		"Dev":    "con",
+	})
+	d.Set("bin", "")
+	if err := d.SetDelims(template.Delims{Left: "<%", Right: "%>"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := map[string]string{
+		"{Name|lower}.go":       "proj.go",
+		"{?bin}main.go{?}":      "main.go",
+		"{?lib}lib.go{?}":       "",
+		"{Module|path}":         "github.com/me/proj",
+		"{Name}.{?bin}bin{?}":   "Proj.bin",
+		"console.{Name|lower}":  "console.proj",
+		"{Module|kebab|path}":   "github-com-me-proj",
+		"{Name}-{Module|path}":  "Proj-github.com/me/proj",
+		"{Module|path}{Name}":   "github.com/me/projProj",
+		"<%Name%>":              "<%Name%>",
+		"{Missing:-}":           "",
+		"{Missing:-default}.md": "default.md",
+	}
+
+	for name, expected := range tests {
+		if actual, err := expandName(d, name); err != nil {
+			t.Errorf("unexpected error expanding '%s': %v", name, err)
+		} else if actual != expected {
+			t.Errorf("expected '%s' to expand to '%s', got '%s'", name,
+				expected, actual)
+		}
+	}
+
+	for _, name := range []string{
+		"{Module}",
+		"{Module|lower}",
+		"{Dots}",
+		"{Dots|path}",
+		"{Module}{Name|path}",
+		"{Module|path}/{Module}",
+		"{Dev}.{Module|path}",
+		"{Dev}",
+		"{Dev}.txt",
+		"{Name|nope}",
+		"{Missing}",
+	} {
+		if actual, err := expandName(d, name); err == nil {
+			t.Errorf("expected error expanding '%s', got '%s'", name,
+				actual)
+		}
+	}
+}
+
+func TestExpandNameEscaped(t *testing.T) {
+	d := template.NewDict(map[string]string{"Name": `Bob's "Proj"`})
+	if err := d.SetEscaping("shell"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `Bob's "Proj"`
+	if actual, err := expandName(d, "{Name}"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if actual != expected {
+		t.Errorf("expected '%s', got '%s'", expected, actual)
+	}
+	if d.Escaping() != "shell" {
+		t.Errorf("expected escaping 'shell', got '%s'", d.Escaping())
+	}
+}
@@ -6,25 +6,114 @@
 package proj
 
 import (
+	"bake/recipe"
 	"bake/template"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
 )
 
+// TypesVar is the template variable that lists the types of a project.
+const TypesVar = "Types"
+
 type Project struct {
 	lang    string
 	types   []string
 	verbose bool
 	dict    *template.Dict
+	given   map[string]string // The variables that the project was given
+	out     io.Writer         // Where progress is printed
+	results []Result
 }
 
-func New(lg string, ts []string, v bool, vs map[string]string) Project {
-	d := template.Dict{}
-	for name, val := range vs {
-		d[name] = val
+// New returns a project in the language `lg` of the types `ts`, whose templates
+// are expanded with the variables `vs` along with the default values of the
+// variables that the types declare and the variables that the recipe for `lg`
+// derives from them. The variables are given the types that `ts` require, as
+// well as `ts`. An error is returned if the types conflict, or if a derived
+// variable can't be computed or isn't valid, such as when the name of the
+// project can't form an identifier in `lg`.
+func New(lg string, ts []string, v bool, vs map[string]string) (Project,
+	error) {
+
+	r, err := recipe.For(lg)
+	if err != nil {
+		return Project{}, err
+	}
+
+	all, err := requiredTypes(r, ts)
+	if err != nil {
+		return Project{}, err
+	}
+	d := template.NewDict(vs)
+	for _, t := range all {
+		d.Set(t, "")
+	}
+	d.SetList(TypesVar, all)
+
+	decls, err := DeclaredVars(r, all)
+	if err != nil {
+		return Project{}, err
 	}
-	for _, t := range ts {
-		d[t] = ""
+	for _, v := range decls {
+		if _, ok := d.Get(v.Name); !ok && v.Default != "" {
+			d.Set(v.Name, v.Default)
+		}
+	}
+
+	if err = deriveVars(r, d); err != nil {
+		return Project{}, err
+	}
+
+	return Project{lg, ts, v, d, vs, os.Stdout, nil}, nil
+}
+
+// deriveVars sets the variables derived by `r` in `d`, in the order that `r`
+// declares them, so that each can be derived from those before it. A derived
+// variable that's already set in `d` keeps its value, which must still be
+// valid.
+func deriveVars(r recipe.Recipe, d *template.Dict) error {
+	vars, err := r.DerivedVars()
+	if err != nil {
+		return err
 	}
-	return Project{lg, ts, v, &d}
+
+	for _, v := range vars {
+		val, ok := d.Get(v.Name)
+		if !ok {
+			if val, err = Derive(v, d); err != nil {
+				return err
+			}
+		}
+
+		if err = v.Validate(val); err != nil {
+			return err
+		}
+		d.Set(v.Name, val)
+	}
+
+	return nil
+}
+
+// Derive returns the value of `v` expanded with `d`, without validating it.
+func Derive(v *recipe.DerivedVar, d *template.Dict) (string, error) {
+	tmpl, err := template.Parse(v.Name, strings.NewReader(v.Expr))
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err = tmpl.Execute(d, &buf); err != nil {
+		return "", fmt.Errorf("couldn't derive %s: %v", v.Name, err)
+	}
+	return buf.String(), nil
+}
+
+// SetOutput makes `p` print its progress to `w` rather than to stdout.
+func (p *Project) SetOutput(w io.Writer) {
+	p.out = w
 }
 
 func (p *Project) IsOfType(t string) bool {
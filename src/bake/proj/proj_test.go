@@ -0,0 +1,138 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package proj
+
+import (
+	"bake/recipe"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewDerivedVars(t *testing.T) {
+	root := tempRecipe(t, map[string]string{
+		"types/base": "Base\n",
+		"vars": "Package = {ProjectName|camel|lower}\n" +
+			"\tis: go-identifier\n" +
+			"Path = {Package}/{Package}.go\n",
+	})
+	defer os.RemoveAll(root)
+
+	p := newProj(t, nil, map[string]string{"ProjectName": "My-Proj"})
+	expected := map[string]string{
+		"Package": "myproj",
+		"Path":    "myproj/myproj.go",
+	}
+	for name, val := range expected {
+		if actual, _ := p.dict.Get(name); actual != val {
+			t.Errorf("expected %s to be '%s', got '%s'", name, val, actual)
+		}
+	}
+
+	// Variables that are given keep their values, but are still validated.
+	p = newProj(t, nil, map[string]string{
+		"ProjectName": "My-Proj",
+		"Package":     "given",
+	})
+	if actual, _ := p.dict.Get("Path"); actual != "given/given.go" {
+		t.Errorf("expected Path to be 'given/given.go', got '%s'", actual)
+	}
+
+	tests := map[string]map[string]string{
+		"isn't a valid go identifier": {"ProjectName": "2048"},
+		"couldn't derive Package":     {},
+		"'my-pkg' isn't":              {"Package": "my-pkg"},
+	}
+	for msg, vars := range tests {
+		_, err := New("x", nil, false, vars)
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("expected error containing '%s', got: %v", msg, err)
+		}
+	}
+}
+
+func TestNewDeclaredVars(t *testing.T) {
+	root := tempRecipe(t, map[string]string{
+		"types/base": "Base\nvar: License = MIT -- License of the project\n",
+		"types/bin": "Executable\nrequires: lib\n" +
+			"var: Cmd = run\nvar: License = GPL\n",
+		"types/lib": "Library\nvar: Docs\n",
+		"vars":      "Path = {Cmd}.go\n",
+	})
+	defer os.RemoveAll(root)
+
+	p := newProj(t, []string{"bin"}, map[string]string{"ProjectName": "P"})
+	expected := map[string]string{
+		"License": "MIT",
+		"Cmd":     "run",
+		"Path":    "run.go",
+	}
+	for name, val := range expected {
+		if actual, _ := p.dict.Get(name); actual != val {
+			t.Errorf("expected %s to be '%s', got '%s'", name, val, actual)
+		}
+	}
+	if _, ok := p.dict.Get("Docs"); ok {
+		t.Errorf("expected Docs, which has no default, to be unset")
+	}
+
+	p = newProj(t, []string{"bin"}, map[string]string{"License": "BSD"})
+	if actual, _ := p.dict.Get("License"); actual != "BSD" {
+		t.Errorf("expected License to be 'BSD', got '%s'", actual)
+	}
+}
+
+func TestNewRequiredTypes(t *testing.T) {
+	root := tempRecipe(t, map[string]string{
+		"types/base": "Base\n",
+		"types/bin":  "Executable\nrequires: lib\n",
+		"types/lib":  "Library\n",
+		"types/web":  "Web\nconflicts: lib\n",
+		"vars":       "Package = {ProjectName|lower}{?lib}lib{?}\n",
+	})
+	defer os.RemoveAll(root)
+
+	p := newProj(t, []string{"bin"}, map[string]string{"ProjectName": "P"})
+	if actual, _ := p.dict.Get("Package"); actual != "plib" {
+		t.Errorf("expected Package to be 'plib', got '%s'", actual)
+	}
+	types, _ := p.dict.GetList(TypesVar)
+	expectStrs(t, "types", []string{"bin", "lib"}, types)
+
+	_, err := New("x", []string{"bin", "web"}, false, nil)
+	if err == nil {
+		t.Errorf("expected error for conflicting types, got none")
+	}
+}
+
+func TestTestVars(t *testing.T) {
+	root := tempRecipe(t, map[string]string{
+		"types/base": "Base\n",
+		"types/bin":  "Executable\nrequires: lib\n",
+		"types/lib":  "Library\n",
+		"vars": "Package = {ProjectName|lower}{?bin}cmd{?}" +
+			"{?lib}lib{?}\n",
+	})
+	defer os.RemoveAll(root)
+
+	r, err := recipe.For("x")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	vars, err := testVars(r, "Project", []string{"bin"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"ProjectName":      "Project",
+		"ProjectNameLower": "project",
+		"Package":          "projectcmdlib",
+	}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("expected variables %v, got %v", expected, vars)
+	}
+}
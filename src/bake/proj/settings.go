@@ -0,0 +1,148 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package proj
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// SettingsFile is the file in the root directory of a generated project that
+// records the settings that the project was generated with, so that types can
+// be added to it later.
+const SettingsFile = ".bake"
+
+// The keys of the settings file lines that aren't variables.
+const (
+	langKey  = "lang"
+	typesKey = "types"
+
+	settingsKeySep = ":"
+	settingsVarSep = "="
+)
+
+// Settings are what a project was generated with. They're recorded as lines of
+// the form `lang: go` and `types: bin, make`, followed by a line of the form
+// `Name = value` for each variable.
+type Settings struct {
+	Lang  string
+	Types []string
+	Vars  map[string]string // The variables given to bake
+}
+
+// Settings returns the settings that `p` is generated with.
+func (p *Project) Settings() *Settings {
+	vars := make(map[string]string, len(p.given))
+	for name, val := range p.given {
+		vars[name] = val
+	}
+	return &Settings{p.lang, p.types, vars}
+}
+
+// writeSettings records the settings of `p` in the root directory of the
+// project that `entries` generate, if it exists.
+func (p *Project) writeSettings(entries []entry) error {
+	// The root directory is the last entry from the root of the template
+	// directory, as the entries before it lead to it.
+	root := ""
+	for _, e := range entries {
+		if e.src == rootName {
+			root = e.tgt
+		}
+	}
+	if root == "" || !isDir(root) {
+		return nil
+	}
+
+	fpath := path.Join(root, SettingsFile)
+	return ioutil.WriteFile(fpath, []byte(p.Settings().String()), 0666)
+}
+
+func isDir(p string) bool {
+	fi, err := os.Stat(p)
+	return err == nil && fi.IsDir()
+}
+
+// String returns `s` in the form expected by ReadSettings, with the variables
+// sorted by name.
+func (s *Settings) String() string {
+	str := langKey + settingsKeySep + " " + s.Lang + "\n" +
+		typesKey + settingsKeySep + " " + strings.Join(s.Types, ", ") + "\n"
+
+	names := make([]string, 0, len(s.Vars))
+	for name := range s.Vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		str += name + " " + settingsVarSep + " " + s.Vars[name] + "\n"
+	}
+	return str
+}
+
+// ReadSettings returns the settings recorded in the settings file at `fpath`.
+func ReadSettings(fpath string) (*Settings, error) {
+	file, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	s, err := readSettings(bufio.NewScanner(file))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fpath, err)
+	}
+	return s, nil
+}
+
+func readSettings(in *bufio.Scanner) (*Settings, error) {
+	s := &Settings{Vars: map[string]string{}}
+	for n := 1; in.Scan(); n++ {
+		line := in.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if val, ok := settingsVal(line, langKey); ok {
+			s.Lang = val
+			continue
+		} else if val, ok := settingsVal(line, typesKey); ok {
+			for _, t := range strings.Split(val, ",") {
+				if t = strings.TrimSpace(t); t != "" {
+					s.Types = append(s.Types, t)
+				}
+			}
+			continue
+		}
+
+		parts := strings.SplitN(line, settingsVarSep, 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: expected '%s' after the name "+
+				"of the variable", n, settingsVarSep)
+		}
+		s.Vars[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	if err := in.Err(); err != nil {
+		return nil, err
+	}
+
+	if s.Lang == "" {
+		return nil, fmt.Errorf("no language is recorded")
+	}
+	return s, nil
+}
+
+// settingsVal returns the value of `line` if it's the line for `key`.
+func settingsVal(line, key string) (string, bool) {
+	if !strings.HasPrefix(line, key+settingsKeySep) {
+		return "", false
+	}
+	return strings.TrimSpace(line[len(key+settingsKeySep):]), true
+}
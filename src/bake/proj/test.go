@@ -0,0 +1,65 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package proj
+
+import (
+	"bake/recipe"
+	"bake/recipe/test"
+	"bake/template"
+	"strings"
+)
+
+// Test runs the test scripts of the recipe for `lg` and returns true if they
+// pass. The tests can refer to the variables that bake is given for the
+// projects that they generate, and to those that the recipe derives from them.
+func Test(lg string) (bool, error) {
+	r, err := recipe.For(lg)
+	if err != nil {
+		return false, err
+	}
+	scripts, err := r.TestScripts()
+	if err != nil {
+		return false, err
+	}
+	return test.TestRecipe(lg, scripts, func(name string, ts []string) (
+		map[string]string, error) {
+
+		return testVars(r, name, ts)
+	})
+}
+
+// testVars returns the variables of the project named `name` of the types `ts`
+// that the tests of `r` generate, including the variables that `r` derives,
+// which are derived with the types that `ts` require as well as `ts`.
+func testVars(r recipe.Recipe, name string, ts []string) (map[string]string,
+	error) {
+
+	vars := map[string]string{
+		"ProjectName":      name,
+		"ProjectNameLower": strings.ToLower(name),
+	}
+
+	all, err := requiredTypes(r, ts)
+	if err != nil {
+		return nil, err
+	}
+	d := template.NewDict(vars)
+	for _, t := range all {
+		d.Set(t, "")
+	}
+	d.SetList(TypesVar, all)
+	if err = deriveVars(r, d); err != nil {
+		return nil, err
+	}
+
+	derived, err := r.DerivedVars()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range derived {
+		vars[v.Name], _ = d.Get(v.Name)
+	}
+	return vars, nil
+}
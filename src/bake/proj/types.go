@@ -0,0 +1,68 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package proj
+
+import (
+	"bake/recipe"
+	"fmt"
+)
+
+// resolveTypes returns `types` followed by every type that they require,
+// directly or indirectly, in the order that they were found. `header` returns
+// the include file header of a type. An error is returned if any two of the
+// resulting types conflict, in which case nothing should be generated.
+func resolveTypes(types []string,
+	header func(t string) (*recipe.InclHeader, error)) ([]string, error) {
+
+	var resolved []string
+	headers := map[string]*recipe.InclHeader{}
+	requiredBy := map[string]string{}
+
+	queue := append([]string{}, types...)
+	for len(queue) > 0 {
+		t := queue[0]
+		queue = queue[1:]
+		if _, ok := headers[t]; ok {
+			continue
+		}
+
+		h, err := header(t)
+		if err != nil {
+			return nil, err
+		}
+		headers[t] = h
+		resolved = append(resolved, t)
+
+		for _, req := range h.Requires {
+			if _, ok := requiredBy[req]; !ok {
+				requiredBy[req] = t
+			}
+			queue = append(queue, req)
+		}
+	}
+
+	for _, t := range resolved {
+		for _, c := range headers[t].Conflicts {
+			if _, ok := headers[c]; ok {
+				return nil, conflictErr(t, c, requiredBy)
+			}
+		}
+	}
+
+	return resolved, nil
+}
+
+func conflictErr(t, c string, requiredBy map[string]string) error {
+	return fmt.Errorf("type '%s'%s conflicts with type '%s'%s",
+		t, reason(t, requiredBy), c, reason(c, requiredBy))
+}
+
+// reason describes why `t` was included, if it wasn't given explicitly.
+func reason(t string, requiredBy map[string]string) string {
+	if by, ok := requiredBy[t]; ok {
+		return fmt.Sprintf(" (required by '%s')", by)
+	}
+	return ""
+}
@@ -0,0 +1,71 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package proj
+
+import (
+	"bake/recipe"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var typeHeaders = map[string]*recipe.InclHeader{
+	"bin":    {Conflicts: []string{"lib"}},
+	"lib":    {},
+	"make":   {},
+	"docker": {Requires: []string{"bin"}},
+	"deploy": {Requires: []string{"docker", "make"}},
+}
+
+func header(t string) (*recipe.InclHeader, error) {
+	if h, ok := typeHeaders[t]; ok {
+		return h, nil
+	}
+	return nil, fmt.Errorf("unknown type '%s'", t)
+}
+
+func TestResolveTypes(t *testing.T) {
+	tests := map[string]string{
+		"":            "",
+		"make":        "make",
+		"bin,make":    "bin,make",
+		"docker":      "docker,bin",
+		"deploy":      "deploy,docker,make,bin",
+		"make,deploy": "make,deploy,docker,bin",
+		"bin,docker":  "bin,docker",
+		"bin,bin":     "bin",
+	}
+
+	for types, expected := range tests {
+		resolved, err := resolveTypes(split(types), header)
+		if err != nil {
+			t.Errorf("Failed resolving '%s': %v", types, err)
+		} else if strings.Join(resolved, ",") != expected {
+			t.Errorf("Resolving '%s', expected '%s', got '%s'",
+				types, expected, strings.Join(resolved, ","))
+		}
+	}
+}
+
+func split(types string) []string {
+	if len(types) == 0 {
+		return nil
+	}
+	return strings.Split(types, ",")
+}
+
+func TestResolveConflictingTypes(t *testing.T) {
+	for _, types := range []string{"bin,lib", "lib,bin", "lib,docker"} {
+		if _, err := resolveTypes(split(types), header); err == nil {
+			t.Errorf("Expected conflict resolving '%s'", types)
+		}
+	}
+}
+
+func TestResolveUnknownType(t *testing.T) {
+	if _, err := resolveTypes([]string{"x"}, header); err == nil {
+		t.Errorf("Expected error resolving unknown type")
+	}
+}
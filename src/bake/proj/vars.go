@@ -0,0 +1,134 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package proj
+
+import (
+	"bake/recipe"
+	"bake/template"
+	"fmt"
+	"fs"
+	"path"
+	"strings"
+)
+
+// Vars returns the names referred to by the templates and file names that are
+// generated for a project of `types` in `r`, along with the names used in the
+// conditions of include file entries. Variables that are only referred to by
+// entries with conditions are optional.
+func Vars(r recipe.Recipe, types []string) (*template.Analysis, error) {
+	types, err := requiredTypes(r, types)
+	if err != nil {
+		return nil, err
+	}
+
+	filePaths, err := typeFiles(r, append(types, recipe.BaseType))
+	if err != nil {
+		return nil, err
+	}
+	incls, err := ParseInclFiles(filePaths...)
+	if err != nil {
+		return nil, err
+	}
+	root := fs.NewDir("{ProjectName}", incls.Children()...)
+
+	return analyzeNode(r, root, "", false, &template.Analysis{})
+}
+
+// DeclaredVars returns the variables declared by the include files of `types`
+// in `r`, along with the types that they require and the base type, in the
+// order that they're declared, starting with those of the base type. A variable
+// that's declared by more than one type is returned once, as it's first
+// declared.
+func DeclaredVars(r recipe.Recipe, types []string) ([]*recipe.VarDecl, error) {
+	types, err := requiredTypes(r, types)
+	if err != nil {
+		return nil, err
+	}
+
+	var vars []*recipe.VarDecl
+	seen := map[string]bool{}
+	for _, t := range append([]string{recipe.BaseType}, types...) {
+		fpath, err := r.TypeFile(t)
+		if err != nil {
+			return nil, err
+		}
+		h, err := recipe.ParseInclHeader(fpath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", fpath, err)
+		}
+
+		for _, v := range h.Vars {
+			if !seen[v.Name] {
+				seen[v.Name] = true
+				vars = append(vars, v)
+			}
+		}
+	}
+	return vars, nil
+}
+
+// analyzeNode merges the names referred to by `n`, and its contents, into `a`.
+// `srcDir` is the path of the directory containing `n` relative to the template
+// root of `r`, and `cond` is true if `n` is within a directory that has a
+// condition.
+func analyzeNode(r recipe.Recipe, n *fs.Node, srcDir string, cond bool,
+	a *template.Analysis) (*template.Analysis, error) {
+
+	an, err := analyzeName(n.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	if n.Cond() != "" {
+		c, err := template.ParseCond(n.Cond())
+		if err != nil {
+			return nil, err
+		}
+		an = an.Merge(&template.Analysis{Conds: c.Names()})
+		cond = true
+	}
+
+	src := path.Join(srcDir, n.Name())
+	if n.IsDir() {
+		for _, child := range n.Children() {
+			an, err = analyzeNode(r, child, src, cond, an)
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		fpath, err := r.Template(src)
+		if err != nil {
+			return nil, err
+		}
+		delims, err := r.Delims()
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := template.ParseFileWith(fpath, delims)
+		if err != nil {
+			return nil, err
+		}
+		tan, err := tmpl.Analyze(r.Partial)
+		if err != nil {
+			return nil, err
+		}
+		an = an.Merge(tan)
+	}
+
+	if cond {
+		an = an.Conditional()
+	}
+	return a.Merge(an), nil
+}
+
+// analyzeName returns the names referred to by the file name `name`.
+func analyzeName(name string) (*template.Analysis, error) {
+	tmpl, err := template.Parse(name, strings.NewReader(name))
+	if err != nil {
+		return nil, err
+	}
+	return tmpl.Analyze(nil)
+}
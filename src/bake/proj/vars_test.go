@@ -0,0 +1,75 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package proj
+
+import (
+	"bake/recipe"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestVars(t *testing.T) {
+	root := tempRecipe(t, map[string]string{
+		"types/base": "Base\na\nb {?Email}\n",
+		"types/bin": "Executable\nrequires: lib\n" +
+			"src/\n\t{Cmd|lower}\n",
+		"types/lib":                               "Library\n",
+		"templates/{ProjectName}/a":               "{Owner}{?bin} {>p}{?}\n",
+		"templates/{ProjectName}/b":               "{Email}\n",
+		"templates/{ProjectName}/src/{Cmd|lower}": "{*Types:T}{T}{Sep}{*}",
+		"partials/p":                              "{Year}\n",
+	})
+	defer os.RemoveAll(root)
+
+	r, err := recipe.For("x")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	a, err := Vars(r, []string{"bin"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectStrs(t, "required variables",
+		[]string{"Cmd", "Owner", "ProjectName", "Sep", "Types"}, a.Required)
+	expectStrs(t, "optional variables", []string{"Email", "Year"},
+		a.Optional)
+	expectStrs(t, "conditions", []string{"Email", "bin"}, a.Conds)
+
+	a, err = Vars(r, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectStrs(t, "required variables", []string{"Owner", "ProjectName"},
+		a.Required)
+}
+
+func TestDeclaredVars(t *testing.T) {
+	root := tempRecipe(t, map[string]string{
+		"types/base": "Base\nvar: A = 1\n",
+		"types/bin":  "Executable\nrequires: lib\nvar: B\nvar: A = 2\n",
+		"types/lib":  "Library\nvar: C -- c\n",
+	})
+	defer os.RemoveAll(root)
+
+	r, err := recipe.For("x")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	vars, err := DeclaredVars(r, []string{"bin"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var actual []string
+	for _, v := range vars {
+		actual = append(actual, v.String())
+	}
+	expected := []string{"A = 1", "B", "C -- c"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
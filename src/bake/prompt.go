@@ -0,0 +1,233 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bake/env"
+	"bake/proj"
+	"bake/recipe"
+	"bake/template"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// errNoAnswer is returned when the input ends before a question is answered.
+var errNoAnswer = errors.New("input ended before all questions were answered")
+
+// A prompter asks questions on `out` and reads the answers from `in`.
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func newPrompter(in io.Reader, out io.Writer) *prompter {
+	return &prompter{bufio.NewReader(in), out}
+}
+
+// isTerminal returns true if `f` is a terminal, so that questions can be
+// asked on it. Terminals are character devices, but so is the null device,
+// which is ruled out.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
+
+// ask asks `question` until it gets an answer that `valid` accepts, and returns
+// that answer. An empty answer is taken to be `def`, which is shown with the
+// question if it isn't empty. `valid` may be nil, in which case any answer is
+// accepted.
+func (p *prompter) ask(question, def string, valid func(string) error) (
+	string, error) {
+
+	for {
+		if def == "" {
+			fmt.Fprintf(p.out, "%s: ", question)
+		} else {
+			fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+		}
+
+		line, err := p.in.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			fmt.Fprintln(p.out)
+			if err == io.EOF {
+				return "", errNoAnswer
+			}
+			return "", err
+		}
+
+		answer := strings.TrimSpace(line)
+		if answer == "" {
+			answer = def
+		}
+		if valid == nil {
+			return answer, nil
+		}
+		if err = valid(answer); err == nil {
+			return answer, nil
+		}
+		fmt.Fprintf(p.out, "%v\n", err)
+	}
+}
+
+func required(what string) func(string) error {
+	return func(s string) error {
+		if s == "" {
+			return fmt.Errorf("The %s is required", what)
+		}
+		return nil
+	}
+}
+
+// promptArgs asks for the required arguments that weren't given, and for the
+// types of the project if none were given.
+func promptArgs(p *prompter) error {
+	var err error
+	if lang == "" {
+		if lang, err = promptLang(p); err != nil {
+			return err
+		}
+	}
+	if owner == "" {
+		if owner, err = p.ask("Owner", "", required("owner")); err != nil {
+			return err
+		}
+	}
+	if name == "" {
+		if name, err = p.ask("Name", "", required("name")); err != nil {
+			return err
+		}
+	}
+	if len(types) == 0 {
+		if types, err = promptTypes(p, lang); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func promptLang(p *prompter) (string, error) {
+	langs, err := env.SupportedLangs()
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(langs)
+
+	fmt.Fprintf(p.out, "Languages: %s\n", strings.Join(langs, ", "))
+	return p.ask("Language", "", func(s string) error {
+		i := sort.SearchStrings(langs, s)
+		if i == len(langs) || langs[i] != s {
+			return fmt.Errorf("'%s' is not a valid language", s)
+		}
+		return nil
+	})
+}
+
+// promptTypes asks for the types of a project in `lang`, after listing them as
+// `-T` does.
+func promptTypes(p *prompter, lang string) ([]string, error) {
+	r, err := recipe.For(lang)
+	if err != nil {
+		return nil, err
+	}
+	typeNames, err := r.Types()
+	if err != nil {
+		return nil, err
+	}
+	known := map[string]bool{}
+	for _, t := range typeNames {
+		known[t] = true
+	}
+
+	printTypesFor(p.out, lang, textFormat)
+
+	var ts []string
+	_, err = p.ask("Types (comma-separated)", "", func(s string) error {
+		ts = nil
+		for _, t := range strings.Split(s, ",") {
+			t = strings.TrimSpace(t)
+			if t == "" {
+				continue
+			} else if !known[t] {
+				return fmt.Errorf("'%s' is not a type of %s project", t,
+					lang)
+			}
+			ts = append(ts, t)
+		}
+		_, err := proj.DeclaredVars(r, ts)
+		return err
+	})
+	return ts, err
+}
+
+// promptVars asks for the variables that the types `ts` of a project in `lang`
+// declare, and for the variables that its recipe derives, that aren't already
+// in `vars`, and adds the answers to `vars`. Declared variables default to the
+// default of their declaration, and derived variables default to their derived
+// values.
+func promptVars(p *prompter, lang string, ts []string,
+	vars map[string]string) error {
+
+	r, err := recipe.For(lang)
+	if err != nil {
+		return err
+	}
+
+	decls, err := proj.DeclaredVars(r, ts)
+	if err != nil {
+		return err
+	}
+	for _, v := range decls {
+		if _, ok := vars[v.Name]; ok {
+			continue
+		}
+
+		question := v.Name
+		if v.Descr != "" {
+			question = v.Descr + " (" + v.Name + ")"
+		}
+		val, err := p.ask(question, v.Default, nil)
+		if err != nil {
+			return err
+		}
+		if val != "" {
+			vars[v.Name] = val
+		}
+	}
+
+	derived, err := r.DerivedVars()
+	if err != nil {
+		return err
+	}
+	for _, v := range derived {
+		if _, ok := vars[v.Name]; ok {
+			continue
+		}
+
+		d := template.NewDict(vars)
+		for _, t := range ts {
+			d.Set(t, "")
+		}
+		d.SetList(proj.TypesVar, ts)
+
+		// A value that can't be derived is left for the user to give.
+		def, _ := proj.Derive(v, d)
+		val, err := p.ask(v.Name, def, v.Validate)
+		if err != nil {
+			return err
+		}
+		vars[v.Name] = val
+	}
+
+	return nil
+}
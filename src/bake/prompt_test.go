@@ -0,0 +1,52 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPromptAsk(t *testing.T) {
+	var out bytes.Buffer
+	p := newPrompter(strings.NewReader("\n  x1 \nx\n\ny"), &out)
+	isX := func(s string) error {
+		if s != "x" {
+			return fmt.Errorf("'%s' isn't x", s)
+		}
+		return nil
+	}
+
+	tests := []struct {
+		def      string
+		valid    func(string) error
+		expected string
+	}{
+		{"d", nil, "d"},
+		{"", isX, "x"},
+		{"", nil, ""},
+		{"", nil, "y"},
+	}
+	for _, test := range tests {
+		answer, err := p.ask("Q", test.def, test.valid)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		} else if answer != test.expected {
+			t.Errorf("Expected answer '%s', got '%s'", test.expected, answer)
+		}
+	}
+
+	expected := "Q [d]: Q: 'x1' isn't x\nQ: Q: Q: "
+	if out.String() != expected {
+		t.Errorf("Expected output '%s', got '%s'", expected, out.String())
+	}
+
+	if _, err := p.ask("Q", "d", nil); err != errNoAnswer {
+		t.Errorf("Expected '%v' at the end of input, got '%v'", errNoAnswer,
+			err)
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2012-2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package recipe
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Separates the key of an include file header line from its values.
+const inclKeySep = ":"
+
+// The keys of the include file header lines.
+const (
+	requiresKey  = "requires" // Lists types
+	conflictsKey = "conflicts"
+	varKey       = "var" // Declares a variable
+)
+
+const (
+	varDefaultSep = "="    // Separates a declared variable from its default
+	varDescrSep   = " -- " // Begins the description of a declared variable
+)
+
+// An InclHeader holds the information at the start of an include file, which
+// consists of a one line description followed by optional lines of the form
+// `key: value, ...`.
+type InclHeader struct {
+	Descr     string
+	Requires  []string   // Types that must be generated with this type
+	Conflicts []string   // Types that can't be generated with this type
+	Vars      []*VarDecl // Variables that this type's templates use
+}
+
+// A VarDecl declares a variable that the templates of a type use, which is
+// given by a header line of the form `var: Name = default -- description`,
+// where the default value and the description are optional.
+type VarDecl struct {
+	Name    string
+	Default string
+	Descr   string
+}
+
+// parseVarDecl parses the value of a `var` header line.
+func parseVarDecl(val string) (*VarDecl, error) {
+	v := &VarDecl{}
+	if i := strings.Index(val, varDescrSep); i >= 0 {
+		v.Descr = strings.TrimSpace(val[i+len(varDescrSep):])
+		val = val[:i]
+	}
+	if i := strings.Index(val, varDefaultSep); i >= 0 {
+		v.Default = strings.TrimSpace(val[i+len(varDefaultSep):])
+		val = val[:i]
+	}
+	v.Name = strings.TrimSpace(val)
+
+	if v.Name == "" || !unicode.IsUpper([]rune(v.Name)[0]) ||
+		strings.IndexFunc(v.Name, unicode.IsSpace) >= 0 {
+
+		return nil, fmt.Errorf("'%s' isn't a valid variable name", v.Name)
+	}
+	return v, nil
+}
+
+// String returns `v` in the form expected by parseVarDecl.
+func (v *VarDecl) String() string {
+	s := v.Name
+	if v.Default != "" {
+		s += " " + varDefaultSep + " " + v.Default
+	}
+	if v.Descr != "" {
+		s += varDescrSep + v.Descr
+	}
+	return s
+}
+
+// Return the header of the include file at `path`.
+func ParseInclHeader(path string) (*InclHeader, error) {
+	file, err := os.OpenFile(path, os.O_RDONLY, 0666)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	h, _, err := ReadInclHeader(bufio.NewReader(file))
+	return h, err
+}
+
+// ReadInclHeader reads the header of an include file from `in`, returning it
+// along with the line following it, which has also been consumed from `in`.
+// The description needn't end with a newline.
+func ReadInclHeader(in *bufio.Reader) (*InclHeader, string, error) {
+	descr, err := in.ReadString('\n')
+	h := &InclHeader{Descr: strings.TrimRight(descr, "\n\r")}
+	if err == io.EOF {
+		return h, "", nil
+	} else if err != nil {
+		return nil, "", err
+	}
+
+	for {
+		line, err := in.ReadString('\n')
+		if len(line) == 0 {
+			if err == io.EOF {
+				err = nil
+			}
+			return h, "", err
+		}
+
+		key, val, ok := parseHeaderLine(strings.TrimRight(line, "\n\r"))
+		if !ok {
+			return h, line, nil
+		}
+
+		switch key {
+		case requiresKey:
+			h.Requires = append(h.Requires, splitHeaderList(val)...)
+		case conflictsKey:
+			h.Conflicts = append(h.Conflicts, splitHeaderList(val)...)
+		case varKey:
+			v, err := parseVarDecl(val)
+			if err != nil {
+				return nil, "", err
+			}
+			h.Vars = append(h.Vars, v)
+		}
+
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return h, "", err
+		}
+	}
+}
+
+// Split a header line into its key and value. `ok` is false if `line` isn't a
+// header line, in which case it's the first line that lists files.
+func parseHeaderLine(line string) (key, val string, ok bool) {
+	parts := strings.SplitN(line, inclKeySep, 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+
+	key = parts[0]
+	if key != requiresKey && key != conflictsKey && key != varKey {
+		return "", "", false
+	}
+	return key, parts[1], true
+}
+
+// Split the value of a header line that lists types into the types.
+func splitHeaderList(val string) []string {
+	return strings.FieldsFunc(val, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+}
+
+// Return the header line for `key` and `vals`, or "" if `vals` is empty.
+func headerLine(key string, vals []string) string {
+	if len(vals) == 0 {
+		return ""
+	}
+	return key + inclKeySep + " " + strings.Join(vals, ", ") + "\n"
+}
+
+// Return the header lines of `h`, excluding the description, in the form
+// expected by ParseInclHeader.
+func (h *InclHeader) String() string {
+	s := headerLine(requiresKey, h.Requires) +
+		headerLine(conflictsKey, h.Conflicts)
+	for _, v := range h.Vars {
+		s += varKey + inclKeySep + " " + v.String() + "\n"
+	}
+	return s
+}
@@ -0,0 +1,108 @@
+// Copyright 2012-2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package recipe
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestReadInclHeader(t *testing.T) {
+	source := "" +
+		"Builds a docker image\n" +
+		"requires: bin, make\n" +
+		"conflicts: lib\n" +
+		"requires: net\n" +
+		"Dockerfile\n"
+
+	h, rest, err := ReadInclHeader(bufio.NewReader(
+		strings.NewReader(source)))
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	if h.Descr != "Builds a docker image" {
+		t.Errorf("Expected description 'Builds a docker image', got '%s'",
+			h.Descr)
+	}
+	expectStrs(t, "requires", []string{"bin", "make", "net"}, h.Requires)
+	expectStrs(t, "conflicts", []string{"lib"}, h.Conflicts)
+
+	if rest != "Dockerfile\n" {
+		t.Errorf("Expected first entry 'Dockerfile', got '%s'", rest)
+	}
+}
+
+func TestReadInclHeaderVars(t *testing.T) {
+	source := "" +
+		"Hosts a server\n" +
+		"var: Port = 8080 -- The port that the server listens on\n" +
+		"var: Host -- The name of the server's host\n" +
+		"var: Motd = Hi -- there\n" +
+		"var:Root\n" +
+		"server.go\n"
+
+	h, _, err := ReadInclHeader(bufio.NewReader(strings.NewReader(source)))
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	expected := []VarDecl{
+		{"Port", "8080", "The port that the server listens on"},
+		{"Host", "", "The name of the server's host"},
+		{"Motd", "Hi", "there"},
+		{"Root", "", ""},
+	}
+	if len(h.Vars) != len(expected) {
+		t.Fatalf("Expected %d variables, got %d", len(expected),
+			len(h.Vars))
+	}
+	for i, v := range expected {
+		if *h.Vars[i] != v {
+			t.Errorf("Expected variable %v, got %v", v, *h.Vars[i])
+		}
+	}
+
+	for _, line := range []string{"var:", "var: port", "var: A B = c"} {
+		_, _, err := ReadInclHeader(bufio.NewReader(
+			strings.NewReader("d\n" + line + "\n")))
+		if err == nil {
+			t.Errorf("Expected error reading '%s'", line)
+		}
+	}
+}
+
+func TestReadInclHeaderOnly(t *testing.T) {
+	sources := []string{"", "d", "d\n", "d\nrequires: a", "d\nrequires: a\n"}
+	for _, source := range sources {
+		_, rest, err := ReadInclHeader(bufio.NewReader(
+			strings.NewReader(source)))
+		if err != nil {
+			t.Errorf("Failed parsing '%s': %v", source, err)
+		} else if rest != "" {
+			t.Errorf("Expected no entries in '%s', got '%s'",
+				source, rest)
+		}
+	}
+}
+
+func TestInclHeaderString(t *testing.T) {
+	h := &InclHeader{"d", []string{"a", "b"}, []string{"c"}, []*VarDecl{
+		{"License", "MIT", "The project's license"},
+		{"Host", "", ""},
+	}}
+	expected := "requires: a, b\nconflicts: c\n" +
+		"var: License = MIT -- The project's license\nvar: Host\n"
+	if h.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, h.String())
+	}
+}
+
+func expectStrs(t *testing.T, descr string, expected, actual []string) {
+	if strings.Join(expected, ",") != strings.Join(actual, ",") {
+		t.Errorf("Expected %s %v, got %v", descr, expected, actual)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package install
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// The extensions of the archives that recipes can be installed from.
+var archiveExts = []string{".tar.gz", ".tgz", ".tar", ".zip"}
+
+// copyTree copies the contents of the directory `src` into `dest`, which is
+// created if it doesn't exist.
+func copyTree(src, dest string) error {
+	return filepath.Walk(src, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() && fi.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		tgt := filepath.Join(dest, rel)
+		if fi.IsDir() {
+			return os.MkdirAll(tgt, 0777)
+		} else if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		in, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		return writeFile(tgt, in, fi.Mode())
+	})
+}
+
+func writeFile(p string, in io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0777); err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC,
+		mode.Perm()|0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// unpack extracts the archive `src` into `dest`.
+func unpack(src, dest string) error {
+	switch {
+	case strings.HasSuffix(src, ".zip"):
+		return unzip(src, dest)
+	case strings.HasSuffix(src, ".tar"):
+		return untar(src, dest, false)
+	case strings.HasSuffix(src, ".tar.gz"), strings.HasSuffix(src, ".tgz"):
+		return untar(src, dest, true)
+	}
+	return fmt.Errorf("'%s' is not a directory or a supported archive (%s)",
+		src, strings.Join(archiveExts, ", "))
+}
+
+func untar(src, dest string, gzipped bool) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var in io.Reader = file
+	if gzipped {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		in = gz
+	}
+
+	tr := tar.NewReader(in)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		tgt, err := archivePath(dest, hdr.Name)
+		if err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(tgt, 0777)
+		case tar.TypeReg:
+			err = writeFile(tgt, tr, hdr.FileInfo().Mode())
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func unzip(src, dest string) error {
+	zr, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		tgt, err := archivePath(dest, f.Name)
+		if err != nil {
+			return err
+		}
+
+		if f.FileInfo().IsDir() {
+			if err = os.MkdirAll(tgt, 0777); err != nil {
+				return err
+			}
+			continue
+		}
+
+		in, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = writeFile(tgt, in, f.Mode())
+		in.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// archivePath returns the path that the archive entry `name` is extracted to
+// in `dest`, and fails if the entry would be extracted outside of `dest`.
+func archivePath(dest, name string) (string, error) {
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("archive entry '%s' is outside "+
+				"the archive", name)
+		}
+	}
+	return filepath.Join(dest, filepath.FromSlash(path.Clean("/"+name))), nil
+}
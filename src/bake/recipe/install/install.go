@@ -0,0 +1,350 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+// Package install manages the recipes installed in the user recipe directory.
+package install
+
+import (
+	"bake/env"
+	"bake/proj"
+	"bake/recipe"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	infoFile    = ".installed" // Records the source of an installed recipe
+	versionFile = "VERSION"    // Optionally holds the version of a recipe
+	recipeSufx  = "-recipe"    // Conventional suffix of recipe names
+
+	unknown = "unknown"
+)
+
+// An Installed describes a recipe in the user recipe directory.
+type Installed struct {
+	Lang    string
+	Source  string // The path that the recipe was installed from
+	Version string
+}
+
+// Install validates the recipes found at `src`, which is a directory, a git
+// repository or an archive, and copies them to the user recipe directory. If
+// `src` holds a single recipe rather than a directory of recipes, then `lang`
+// is used as its language, or its name is derived from `src` if `lang` is
+// empty. Installed recipes are only replaced if `force` is true.
+func Install(src, lang string, force bool) ([]Installed, error) {
+	src, err := filepath.Abs(src)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := ioutil.TempDir("", "bake-recipe")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	version, err := fetch(src, tmp)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(lang) == 0 {
+		lang = recipeName(src)
+	}
+	found, err := findRecipes(tmp, lang)
+	if err != nil {
+		return nil, err
+	} else if len(found) == 0 {
+		return nil, fmt.Errorf("'%s' doesn't contain a recipe", src)
+	}
+
+	userPath, err := env.UserRecipesPath()
+	if err != nil {
+		return nil, err
+	}
+
+	langs := make([]string, 0, len(found))
+	for l, dir := range found {
+		if err = checkLang(l); err != nil {
+			return nil, err
+		}
+		if err = check(l, dir); err != nil {
+			// Paths are reported relative to `src` rather than to
+			// its temporary copy.
+			msg := strings.Replace(err.Error(), tmp, src, -1)
+			return nil, errors.New(msg)
+		}
+		if !force && exists(path.Join(userPath, l)) {
+			return nil, fmt.Errorf("a %s recipe is already installed", l)
+		}
+		langs = append(langs, l)
+	}
+	sort.Strings(langs)
+
+	if err = os.MkdirAll(userPath, 0777); err != nil {
+		return nil, err
+	}
+
+	installed := make([]Installed, len(langs))
+	for i, l := range langs {
+		// A version file is only read if the version isn't known from
+		// `git describe`.
+		v := version
+		if v == unknown {
+			data, err := ioutil.ReadFile(path.Join(found[l], versionFile))
+			if err == nil {
+				v = strings.TrimSpace(string(data))
+			}
+		}
+		installed[i] = Installed{l, src, v}
+
+		err = put(found[l], path.Join(userPath, l), installed[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return installed, nil
+}
+
+// fetch copies or unpacks `src` to `dir` and returns its version, if known.
+func fetch(src, dir string) (string, error) {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return "", err
+	}
+
+	if !fi.IsDir() {
+		return unknown, unpack(src, dir)
+	}
+
+	if !isGitRepo(src) {
+		return unknown, copyTree(src, dir)
+	}
+
+	// Cloning copies the committed state of the repository, rather than
+	// the state of its working tree.
+	if err = git("", "clone", "--quiet", src, dir); err != nil {
+		return "", err
+	}
+
+	version, err := gitOutput(src, "describe", "--tags", "--always")
+	if err != nil {
+		version = unknown
+	}
+
+	return version, os.RemoveAll(path.Join(dir, ".git"))
+}
+
+// isGitRepo returns true if `dir` is the top level of a git work tree. Other
+// directories in a work tree can't be cloned, so they're copied instead.
+func isGitRepo(dir string) bool {
+	top, err := gitOutput(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return false
+	}
+	return sameDir(top, dir)
+}
+
+func sameDir(a, b string) bool {
+	fa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	fb, err := os.Stat(b)
+	return err == nil && os.SameFile(fa, fb)
+}
+
+func git(dir string, args ...string) error {
+	_, err := gitOutput(dir, args...)
+	return err
+}
+
+func gitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", args[0], err,
+			strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// recipeName derives the name of the recipe at `src` from its base name, so
+// that both `/recipes/go-recipe.tar.gz` and `/recipes/go.git` are named `go`.
+func recipeName(src string) string {
+	name := path.Base(filepath.ToSlash(src))
+	for _, ext := range append(archiveExts, ".git") {
+		if strings.HasSuffix(name, ext) {
+			name = name[:len(name)-len(ext)]
+			break
+		}
+	}
+	return strings.TrimSuffix(name, recipeSufx)
+}
+
+// findRecipes returns the recipes in `dir`, mapped from their language. If
+// `dir` is itself a recipe, it is named `lang`. Otherwise each directory in
+// `dir` that is a recipe is named after that directory. Archives commonly
+// wrap their contents in a single directory, which is looked inside if no
+// recipe is found otherwise.
+func findRecipes(dir, lang string) (map[string]string, error) {
+	if isRecipe(dir) {
+		return map[string]string{lang: dir}, nil
+	}
+
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	found := map[string]string{}
+	var dirs []os.FileInfo
+	for _, fi := range fis {
+		if !fi.IsDir() {
+			continue
+		}
+		dirs = append(dirs, fi)
+		if sub := path.Join(dir, fi.Name()); isRecipe(sub) {
+			found[strings.TrimSuffix(fi.Name(), recipeSufx)] = sub
+		}
+	}
+
+	if len(found) == 0 && len(dirs) == 1 {
+		return findRecipes(path.Join(dir, dirs[0].Name()),
+			strings.TrimSuffix(dirs[0].Name(), recipeSufx))
+	}
+
+	return found, nil
+}
+
+// checkLang returns an error if `lang` can't name a recipe in the user recipe
+// directory. Names that are empty, hidden or contain a path separator are
+// rejected, as are `.` and `..`, which would name the directory or its parent.
+func checkLang(lang string) error {
+	if len(lang) == 0 || strings.ContainsAny(lang, "/\\") || lang[0] == '.' {
+		return fmt.Errorf("'%s' is not a valid language", lang)
+	}
+	return nil
+}
+
+func isRecipe(dir string) bool {
+	fi, err := os.Stat(path.Join(dir, "types"))
+	return err == nil && fi.IsDir()
+}
+
+func exists(p string) bool {
+	_, err := os.Stat(p)
+	return err == nil
+}
+
+// check validates the structure of the recipe for `lang` in `dir`.
+func check(lang, dir string) error {
+	r, err := recipe.Open(lang, dir)
+	if err != nil {
+		return err
+	}
+
+	if _, err = r.TypeFile(recipe.BaseType); err != nil {
+		return fmt.Errorf("%s recipe has no %s type", lang, recipe.BaseType)
+	}
+
+	if err = proj.Check(r, proj.CheckVars); err != nil {
+		return fmt.Errorf("invalid %s recipe: %v", lang, err)
+	}
+
+	return nil
+}
+
+// put copies the recipe in `src` to `dest`, replacing any existing recipe, and
+// records how it was installed.
+func put(src, dest string, inst Installed) error {
+	if err := os.RemoveAll(dest); err != nil {
+		return err
+	}
+	if err := copyTree(src, dest); err != nil {
+		return err
+	}
+
+	info := fmt.Sprintf("source: %s\nversion: %s\n",
+		inst.Source, inst.Version)
+	return ioutil.WriteFile(path.Join(dest, infoFile), []byte(info), 0666)
+}
+
+// List returns the recipes in the user recipe directory, sorted by language.
+func List() ([]Installed, error) {
+	userPath, err := env.UserRecipesPath()
+	if err != nil {
+		return nil, err
+	}
+
+	fis, err := ioutil.ReadDir(userPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var installed []Installed
+	for _, fi := range fis {
+		if fi.IsDir() {
+			inst := Installed{fi.Name(), unknown, unknown}
+			readInfo(path.Join(userPath, fi.Name(), infoFile), &inst)
+			installed = append(installed, inst)
+		}
+	}
+
+	return installed, nil
+}
+
+// readInfo reads the installation record at `p` into `inst`. Recipes that
+// were copied into the user recipe directory by hand have no record, so a
+// missing record is ignored.
+func readInfo(p string, inst *Installed) {
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		parts := strings.SplitN(line, ": ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		switch parts[0] {
+		case "source":
+			inst.Source = parts[1]
+		case "version":
+			inst.Version = parts[1]
+		}
+	}
+}
+
+// Remove deletes the recipe for `lang` from the user recipe directory.
+func Remove(lang string) error {
+	userPath, err := env.UserRecipesPath()
+	if err != nil {
+		return err
+	}
+
+	if err = checkLang(lang); err != nil {
+		return err
+	}
+
+	dest := path.Join(userPath, lang)
+	if !exists(dest) {
+		return fmt.Errorf("no %s recipe is installed", lang)
+	}
+
+	return os.RemoveAll(dest)
+}
@@ -0,0 +1,258 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package install
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"testing"
+)
+
+var recipeFiles = map[string]string{
+	"types/base":                     "\nREADME\n",
+	"types/bin":                      "Produces an executable\nmain\n",
+	"templates/{ProjectName}/README": "{ProjectName} by {Owner}\n",
+	"templates/{ProjectName}/main":   "{?bin}{ProjectNameLower}{?}\n",
+	"VERSION":                        "1.0\n",
+}
+
+// tempUser creates a directory to hold the user recipe directory.
+func tempUser(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "bake")
+	if err != nil {
+		t.Fatalf("couldn't create temporary directory: %v", err)
+	}
+	if err = os.Setenv("XDG_CONFIG_HOME", dir); err != nil {
+		t.Fatalf("couldn't set XDG_CONFIG_HOME: %v", err)
+	}
+	return dir
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, conts := range files {
+		p := path.Join(dir, name)
+		if err := os.MkdirAll(path.Dir(p), 0777); err != nil {
+			t.Fatalf("couldn't create '%s': %v", path.Dir(p), err)
+		}
+		if err := ioutil.WriteFile(p, []byte(conts), 0666); err != nil {
+			t.Fatalf("couldn't write '%s': %v", p, err)
+		}
+	}
+}
+
+func TestInstallDir(t *testing.T) {
+	dir := tempUser(t)
+	defer os.RemoveAll(dir)
+
+	src := path.Join(dir, "src")
+	writeFiles(t, src, recipeFiles)
+
+	expectInstalled(t, src, "x", Installed{"x", src, "1.0"})
+
+	if _, err := Install(src, "x", false); err == nil {
+		t.Errorf("expected error reinstalling without force")
+	}
+	if _, err := Install(src, "x", true); err != nil {
+		t.Errorf("unexpected error reinstalling with force: %v", err)
+	}
+}
+
+func TestInstallGitRepo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+
+	dir := tempUser(t)
+	defer os.RemoveAll(dir)
+
+	src := path.Join(dir, "src")
+	writeFiles(t, src, recipeFiles)
+	cmds := [][]string{
+		{"init", "--quiet"},
+		{"add", "."},
+		{"-c", "user.name=x", "-c", "user.email=x", "commit", "--quiet",
+			"-m", "x"},
+		{"tag", "v2.0"},
+	}
+	for _, args := range cmds {
+		if err := git(src, args...); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// The version from `git describe` takes precedence over the version
+	// file.
+	expectInstalled(t, src, "x", Installed{"x", src, "v2.0"})
+}
+
+func TestInstallGitSubdir(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+
+	dir := tempUser(t)
+	defer os.RemoveAll(dir)
+
+	repo := path.Join(dir, "repo")
+	src := path.Join(repo, "recipes/x")
+	writeFiles(t, src, recipeFiles)
+	if err := git(repo, "init", "--quiet"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A directory inside a repository is copied rather than cloned.
+	expectInstalled(t, src, "x", Installed{"x", src, "1.0"})
+}
+
+func expectInstalled(t *testing.T, src, lang string, expected Installed) {
+	installed, err := Install(src, lang, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	} else if len(installed) != 1 || installed[0] != expected {
+		t.Fatalf("expected to install %v, got %v", expected, installed)
+	}
+
+	listed, err := List()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, inst := range listed {
+		if inst == expected {
+			return
+		}
+	}
+	t.Errorf("expected %v to be listed, got %v", expected, listed)
+}
+
+func TestInstallArchive(t *testing.T) {
+	dir := tempUser(t)
+	defer os.RemoveAll(dir)
+
+	src := path.Join(dir, "y-recipe.tar.gz")
+	writeTarGz(t, src, recipeFiles)
+
+	expectInstalled(t, src, "", Installed{"y", src, "1.0"})
+}
+
+func writeTarGz(t *testing.T, p string, files map[string]string) {
+	out, err := os.Create(p)
+	if err != nil {
+		t.Fatalf("couldn't create '%s': %v", p, err)
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	for name, conts := range files {
+		hdr := &tar.Header{
+			Name:     name,
+			Mode:     0666,
+			Size:     int64(len(conts)),
+			Typeflag: tar.TypeReg,
+		}
+		if err = tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("couldn't write header: %v", err)
+		}
+		if _, err = tw.Write([]byte(conts)); err != nil {
+			t.Fatalf("couldn't write '%s': %v", name, err)
+		}
+	}
+	if err = tw.Close(); err != nil {
+		t.Fatalf("couldn't close archive: %v", err)
+	}
+	if err = gz.Close(); err != nil {
+		t.Fatalf("couldn't close archive: %v", err)
+	}
+}
+
+func TestInstallInvalid(t *testing.T) {
+	invalid := map[string]map[string]string{
+		"bad include file": {"types/bin": "Bad\na/\n"},
+		"bad template":     {"templates/{ProjectName}/README": "{x"},
+		"missing template": {"types/bin": "Bad\nmissing\n"},
+		"missing base":     {"types/base": ""},
+	}
+
+	for descr, changes := range invalid {
+		dir := tempUser(t)
+
+		src := path.Join(dir, "src")
+		writeFiles(t, src, recipeFiles)
+		writeFiles(t, src, changes)
+		if len(changes["types/base"]) == 0 {
+			os.Remove(path.Join(src, "types/base"))
+		}
+
+		if _, err := Install(src, "x", false); err == nil {
+			t.Errorf("expected error installing recipe with %s", descr)
+		}
+		if listed, _ := List(); len(listed) != 0 {
+			t.Errorf("expected no recipes after %s, got %v",
+				descr, listed)
+		}
+
+		os.RemoveAll(dir)
+	}
+}
+
+func TestInstallInvalidLang(t *testing.T) {
+	dir := tempUser(t)
+	defer os.RemoveAll(dir)
+
+	src := path.Join(dir, "src")
+	writeFiles(t, src, recipeFiles)
+	if _, err := Install(src, "x", false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, lang := range []string{".", "..", ".hidden"} {
+		if _, err := Install(src, lang, true); err == nil {
+			t.Errorf("expected error installing recipe as '%s'", lang)
+		}
+		if err := Remove(lang); err == nil {
+			t.Errorf("expected error removing recipe '%s'", lang)
+		}
+	}
+
+	listed, err := List()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	} else if len(listed) != 1 || listed[0].Lang != "x" {
+		t.Errorf("expected only the x recipe, got %v", listed)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	dir := tempUser(t)
+	defer os.RemoveAll(dir)
+
+	src := path.Join(dir, "src")
+	writeFiles(t, src, recipeFiles)
+
+	if _, err := Install(src, "x", false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := Remove("x"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if listed, _ := List(); len(listed) != 0 {
+		t.Errorf("expected no recipes, got %v", listed)
+	}
+	if err := Remove("x"); err == nil {
+		t.Errorf("expected error removing missing recipe")
+	}
+}
+
+func TestArchivePathOutside(t *testing.T) {
+	for _, name := range []string{"../x", "a/../../x"} {
+		if _, err := archivePath("/tmp", name); err == nil {
+			t.Errorf("expected error extracting '%s'", name)
+		}
+	}
+}
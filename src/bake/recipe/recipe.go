@@ -6,62 +6,429 @@
 package recipe
 
 import (
+	"bake/env"
 	"bake/recipe/test"
-	"errors"
+	"bake/template"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path"
+	"sort"
+	"strings"
 )
 
 const (
-	bakeVar    = "BAKE"    // The name of the bake environment variable
-	recipesDir = "recipes" // The directory containing bake recipes
+	// BaseType is the type whose files are included in every project.
+	BaseType = "base"
+
+	templatesDir = "templates" // The recipe directory containing templates
+	typesDir     = "types"     // The recipe directory containing types
+	testsDir     = "tests"     // The recipe directory containing tests
+	partialsDir  = "partials"  // The recipe directory containing partials
+	delimsFile   = "delims"    // The recipe file declaring the delimiters
 )
 
+// A Recipe holds the templates, type include files and tests that bake uses to
+// generate and test projects in a single language.
+//
+// A recipe may be provided by several sources on the search path, in which case
+// the types and templates of each source are merged. If more than one source
+// provides a type or a template with the same name, the one from the source
+// that comes first on the search path is used.
 type Recipe interface {
+	// Lang returns the language that the recipe generates projects in.
 	Lang() string
+
+	// Template returns the path of the template at `rel`, which is relative
+	// to the template root that paths in include files are relative to.
+	Template(rel string) (string, error)
+
+	// Partial returns the path of the partial template named `name`, which
+	// is relative to the recipe's partials directory.
+	Partial(name string) (string, error)
+
+	// Types returns the sorted names of the project types provided by the
+	// recipe, excluding BaseType.
+	Types() ([]string, error)
+
+	// TypeInfo returns the description of the type `t`.
+	TypeInfo(t string) (*TypeInfo, error)
+
+	// TypeInfos returns the descriptions of the types returned by Types, in
+	// the same order.
+	TypeInfos() ([]*TypeInfo, error)
+
+	// TypeFile returns the path of the include file for the type `t`.
+	TypeFile(t string) (string, error)
+
+	// TypeSource returns the source that provides the type `t`.
+	TypeSource(t string) (env.Source, error)
+
+	// TestsPaths returns the directories containing the recipe's test
+	// scripts, in order of precedence.
+	TestsPaths() []string
+
+	// TestScripts returns the recipe's test scripts, sorted by name.
+	TestScripts() ([]test.Script, error)
+
+	// Delims returns the delimiters of the recipe's templates and partials,
+	// which are template.DefaultDelims unless the recipe declares others.
+	Delims() (template.Delims, error)
+
+	// DerivedVars returns the variables that the recipe computes from the
+	// variables given to bake, in the order that they're computed.
+	DerivedVars() ([]*DerivedVar, error)
 }
 
+// For returns the recipe for `lang`, merged from every source on the search
+// path. Self-contained recipes, which keep their templates, types and tests
+// under a single directory, take precedence over recipes that use the split
+// layout.
 func For(lang string) (Recipe, error) {
 	return newRecipeFor(lang)
 }
 
+// Open returns the self-contained recipe for `lang` stored in the directory
+// `dir`, ignoring the search path.
+func Open(lang, dir string) (Recipe, error) {
+	if !isDir(path.Join(dir, typesDir)) {
+		return nil, fmt.Errorf("'%s' doesn't contain a %s directory",
+			dir, typesDir)
+	}
+
+	src := env.Source{Name: dir, Path: path.Dir(dir)}
+	return &recipe{lang, []*layer{newLayer(src, dir)}}, nil
+}
+
 type recipe struct {
-	lang string
-	path string
+	lang   string
+	layers []*layer
+}
+
+// A layer is the part of a recipe that is provided by a single source.
+type layer struct {
+	source    env.Source
+	templates string
+	partials  string
+	types     string
+	tests     string
+	delims    string // The file declaring the delimiters of the templates
+	vars      string // The file declaring the derived variables
 }
 
 func newRecipeFor(lang string) (*recipe, error) {
-	bakePath := os.Getenv(bakeVar)
-	if len(bakePath) == 0 {
-		return nil, errors.New("bake environment variable not set")
+	srcs, err := env.SearchPath()
+	if err != nil {
+		return nil, err
 	}
 
-	recipesPath := path.Join(bakePath, recipesDir)
-	if _, err := os.Stat(recipesPath); os.IsNotExist(err) {
-		return nil, errors.New("bake root doesn't contain recipes dir")
+	r := &recipe{lang, nil}
+	for _, src := range srcs {
+		langRecpPath := path.Join(src.Path, lang)
+		if isDir(path.Join(langRecpPath, typesDir)) {
+			r.layers = append(r.layers, newLayer(src, langRecpPath))
+		}
 	}
 
-	langRecpPath := path.Join(recipesPath, lang)
-	if _, err := os.Stat(langRecpPath); os.IsNotExist(err) {
+	if l := newSplitLayerFor(lang); l != nil {
+		r.layers = append(r.layers, l)
+	}
+
+	if len(r.layers) == 0 {
 		return nil, fmt.Errorf("'%s' is not a valid language", lang)
 	}
 
-	return &recipe{lang, langRecpPath}, nil
+	return r, nil
+}
+
+// newLayer returns the layer for the self-contained recipe in `dir`.
+func newLayer(src env.Source, dir string) *layer {
+	return &layer{
+		src,
+		path.Join(dir, templatesDir),
+		path.Join(dir, partialsDir),
+		path.Join(dir, typesDir),
+		path.Join(dir, testsDir),
+		path.Join(dir, delimsFile),
+		path.Join(dir, varsFile),
+	}
+}
+
+// newSplitLayerFor loads the recipe for `lang` from the split layout, where the
+// templates and include files are stored in `$BAKE/templates/{Language}` and
+// only the tests are stored in `$BAKE/recipes/{Language}`. It returns nil if
+// there is no such recipe.
+func newSplitLayerFor(lang string) *layer {
+	templPath, err := env.TemplatesPath()
+	if err != nil {
+		return nil
+	}
+
+	langTemplPath := path.Join(templPath, lang)
+	if !isDir(langTemplPath) {
+		return nil
+	}
+
+	// The tests of a split recipe are optional, so a missing recipes
+	// directory isn't an error.
+	tests := ""
+	if recipesPath, err := env.RecipesPath(); err == nil {
+		tests = path.Join(recipesPath, lang, testsDir)
+	}
+
+	return &layer{
+		env.Source{Name: env.BakeSource, Path: templPath},
+		langTemplPath,
+		path.Join(langTemplPath, partialsDir),
+		langTemplPath,
+		tests,
+		path.Join(langTemplPath, delimsFile),
+		path.Join(langTemplPath, varsFile),
+	}
+}
+
+func isDir(p string) bool {
+	fi, err := os.Stat(p)
+	return err == nil && fi.IsDir()
+}
+
+func isFile(p string) bool {
+	fi, err := os.Stat(p)
+	return err == nil && !fi.IsDir()
 }
 
 func (r *recipe) Lang() string {
 	return r.lang
 }
 
-func (r *recipe) Path() string {
-	return r.path
+func (r *recipe) Template(rel string) (string, error) {
+	for _, l := range r.layers {
+		if p := path.Join(l.templates, rel); isFile(p) {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("%s recipe has no template '%s'", r.lang, rel)
+}
+
+func (r *recipe) Partial(name string) (string, error) {
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("'%s' is not a valid partial name", name)
+		}
+	}
+
+	for _, l := range r.layers {
+		if p := path.Join(l.partials, name); isFile(p) {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("%s recipe has no partial '%s'", r.lang, name)
+}
+
+func (r *recipe) Types() ([]string, error) {
+	found := map[string]bool{}
+	for _, l := range r.layers {
+		fis, err := ioutil.ReadDir(l.types)
+		if err != nil {
+			return nil, err
+		}
+
+		// Files that can't name types, such as hidden files, those left
+		// by fmtincl.sh and the delimiters and variables files of the
+		// split layout, aren't include files.
+		for _, fi := range fis {
+			name := fi.Name()
+			if !fi.IsDir() && isTypeName(name) && name != BaseType {
+				found[name] = true
+			}
+		}
+	}
+
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (r *recipe) TypeFile(t string) (string, error) {
+	l, err := r.typeLayer(t)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(l.types, t), nil
 }
 
-func Test(lang string) (bool, error) {
-	r, err := newRecipeFor(lang)
+func (r *recipe) typeLayer(t string) (*layer, error) {
+	if !isTypeName(t) {
+		return nil, fmt.Errorf("'%s' can't name a project type", t)
+	}
+	for _, l := range r.layers {
+		if isFile(path.Join(l.types, t)) {
+			return l, nil
+		}
+	}
+	return nil, fmt.Errorf("'%s' is not a valid %s project type", t, r.lang)
+}
+
+// Delims returns the delimiters declared by the first layer that has a
+// delimiters file, which holds the left and right delimiters on a single line,
+// separated by a space.
+func (r *recipe) Delims() (template.Delims, error) {
+	for _, l := range r.layers {
+		if !isFile(l.delims) {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(l.delims)
+		if err != nil {
+			return template.Delims{}, err
+		}
+
+		fields := strings.Fields(string(data))
+		if len(fields) != 2 {
+			return template.Delims{}, fmt.Errorf("'%s' should hold a "+
+				"left and a right delimiter", l.delims)
+		}
+
+		d := template.Delims{Left: fields[0], Right: fields[1]}
+		if err = d.Validate(); err != nil {
+			return template.Delims{}, fmt.Errorf("%s: %v", l.delims, err)
+		}
+		return d, nil
+	}
+	return template.DefaultDelims, nil
+}
+
+func (r *recipe) TypeSource(t string) (env.Source, error) {
+	l, err := r.typeLayer(t)
+	if err != nil {
+		return env.Source{}, err
+	}
+	return l.source, nil
+}
+
+// A TypeInfo describes a project type, as given by its include file.
+type TypeInfo struct {
+	Name      string
+	Descr     string
+	Requires  []string   // Types that must be generated with this type
+	Conflicts []string   // Types that can't be generated with this type
+	Source    env.Source // The source that provides the type
+	Path      string     // The path of the type's include file
+}
+
+func (r *recipe) TypeInfo(t string) (*TypeInfo, error) {
+	l, err := r.typeLayer(t)
+	if err != nil {
+		return nil, err
+	}
+
+	fpath := path.Join(l.types, t)
+	h, err := ParseInclHeader(fpath)
 	if err != nil {
-		return false, err
+		return nil, fmt.Errorf("%s: %v", fpath, err)
+	}
+	return &TypeInfo{t, h.Descr, h.Requires, h.Conflicts, l.source, fpath},
+		nil
+}
+
+func (r *recipe) TypeInfos() ([]*TypeInfo, error) {
+	names, err := r.Types()
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]*TypeInfo, len(names))
+	for i, name := range names {
+		if infos[i], err = r.TypeInfo(name); err != nil {
+			return nil, err
+		}
+	}
+	return infos, nil
+}
+
+// isTypeName returns true if `s` can name a type, which starts with a lower
+// case letter, followed by lower case letters, digits and `-`. Types can't
+// contain `_`, as it separates the types in the names of test scripts, and
+// can't be named after the delimiters and variables files, as the split layout
+// stores them alongside the include files.
+func isTypeName(s string) bool {
+	if s == delimsFile || s == varsFile {
+		return false
+	}
+	for i, r := range s {
+		valid := r >= 'a' && r <= 'z' ||
+			i > 0 && (r >= '0' && r <= '9' || r == '-')
+		if !valid {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func (r *recipe) TestsPaths() []string {
+	paths := make([]string, 0, len(r.layers))
+	for _, l := range r.layers {
+		if isDir(l.tests) {
+			paths = append(paths, l.tests)
+		}
+	}
+	return paths
+}
+
+// TestScripts returns the test scripts in the directories returned by
+// TestsPaths. If more than one directory contains a test script with the same
+// name, only the script in the first such directory is returned. Files whose
+// names aren't lists of type names, such as hidden files, aren't test scripts,
+// but a script for a type that the recipe doesn't have is an error.
+func (r *recipe) TestScripts() ([]test.Script, error) {
+	found := map[string]test.Script{}
+	for _, dir := range r.TestsPaths() {
+		fis, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, fi := range fis {
+			name := fi.Name()
+			if _, ok := found[name]; ok || fi.IsDir() {
+				continue
+			}
+			ts := test.ScriptTypes(name)
+			if !allTypeNames(ts) {
+				continue
+			}
+			for _, t := range ts {
+				if _, err := r.TypeInfo(t); err != nil {
+					return nil, fmt.Errorf("%s: %v", path.Join(dir, name),
+						err)
+				}
+			}
+			found[name] = test.Script{Types: ts, Path: path.Join(dir, name)}
+		}
+	}
+
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	scripts := make([]test.Script, len(names))
+	for i, name := range names {
+		scripts[i] = found[name]
+	}
+	return scripts, nil
+}
+
+func allTypeNames(names []string) bool {
+	for _, name := range names {
+		if !isTypeName(name) {
+			return false
+		}
 	}
-	return test.TestRecipe(r.Lang(), r.Path())
+	return true
 }
@@ -0,0 +1,431 @@
+// Copyright 2013 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package recipe
+
+import (
+	"bake/recipe/test"
+	"bake/template"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestForSelfContained(t *testing.T) {
+	root := tempBake(t)
+	defer os.RemoveAll(root)
+
+	mkdirs(t, root, "recipes/x/tests")
+	mkfiles(t, root,
+		"recipes/x/types/base",
+		"recipes/x/types/bin",
+		"recipes/x/templates/{ProjectName}/a",
+	)
+
+	r, err := For("x")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectTemplate(t, r, path.Join(root, "recipes/x/templates"))
+	expectPaths(t, r.TestsPaths(), path.Join(root, "recipes/x/tests"))
+	expectTypes(t, r, "bin")
+}
+
+// tempBake creates a bake root that is the only source on the search path.
+func tempBake(t *testing.T) string {
+	root, err := ioutil.TempDir("", "bake")
+	if err != nil {
+		t.Fatalf("couldn't create bake root: %v", err)
+	}
+
+	vars := map[string]string{
+		"BAKE":            root,
+		"BAKE_PATH":       "",
+		"XDG_CONFIG_HOME": path.Join(root, "config"),
+	}
+	for name, val := range vars {
+		if err = os.Setenv(name, val); err != nil {
+			t.Fatalf("couldn't set %s: %v", name, err)
+		}
+	}
+
+	return root
+}
+
+func mkdirs(t *testing.T, root string, dirs ...string) {
+	for _, dir := range dirs {
+		if err := os.MkdirAll(path.Join(root, dir), 0777); err != nil {
+			t.Fatalf("couldn't create '%s': %v", dir, err)
+		}
+	}
+}
+
+func mkfiles(t *testing.T, root string, files ...string) {
+	for _, file := range files {
+		mkdirs(t, root, path.Dir(file))
+		err := ioutil.WriteFile(path.Join(root, file), []byte("\n"), 0666)
+		if err != nil {
+			t.Fatalf("couldn't create '%s': %v", file, err)
+		}
+	}
+}
+
+func expectTemplate(t *testing.T, r Recipe, root string) {
+	expected := path.Join(root, "{ProjectName}/a")
+	if actual, err := r.Template("{ProjectName}/a"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if expected != actual {
+		t.Errorf("expected '%s', got '%s'", expected, actual)
+	}
+}
+
+func expectPaths(t *testing.T, actual []string, expected ...string) {
+	if len(actual) != len(expected) {
+		t.Fatalf("expected paths %v, got %v", expected, actual)
+	}
+	for i, p := range expected {
+		if p != actual[i] {
+			t.Errorf("expected '%s', got '%s'", p, actual[i])
+		}
+	}
+}
+
+func expectTypes(t *testing.T, r Recipe, expected ...string) {
+	infos, err := r.TypeInfos()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(infos) != len(expected) {
+		t.Fatalf("expected %d types, got %d", len(expected), len(infos))
+	}
+	for i, name := range expected {
+		if infos[i].Name != name {
+			t.Errorf("expected type '%s', got '%s'", name, infos[i].Name)
+		}
+		if fpath, err := r.TypeFile(name); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		} else if infos[i].Path != fpath {
+			t.Errorf("expected path '%s', got '%s'", fpath, infos[i].Path)
+		}
+	}
+}
+
+func TestForSplit(t *testing.T) {
+	root := tempBake(t)
+	defer os.RemoveAll(root)
+
+	mkdirs(t, root, "recipes/x/tests")
+	mkfiles(t, root,
+		"templates/x/base",
+		"templates/x/bin",
+		"templates/x/delims",
+		"templates/x/vars",
+		"templates/x/{ProjectName}/a",
+	)
+
+	r, err := For("x")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectTemplate(t, r, path.Join(root, "templates/x"))
+	expectPaths(t, r.TestsPaths(), path.Join(root, "recipes/x/tests"))
+	expectTypes(t, r, "bin")
+
+	// The delimiters and variables files aren't types.
+	for _, name := range []string{"delims", "vars"} {
+		if _, err := r.TypeFile(name); err == nil {
+			t.Errorf("expected error for the type '%s', got none", name)
+		}
+		if _, err := r.TypeInfo(name); err == nil {
+			t.Errorf("expected error for the info of '%s', got none", name)
+		}
+	}
+}
+
+func TestForSearchPath(t *testing.T) {
+	root := tempBake(t)
+	defer os.RemoveAll(root)
+
+	mkfiles(t, root,
+		"recipes/x/types/base",
+		"recipes/x/types/bin",
+		"recipes/x/templates/{ProjectName}/a",
+		"recipes/x/templates/{ProjectName}/b",
+		"extra/x/types/bin",
+		"extra/x/types/lib",
+		"extra/x/templates/{ProjectName}/b",
+	)
+	os.Setenv("BAKE_PATH", path.Join(root, "extra"))
+
+	r, err := For("x")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectTypes(t, r, "bin", "lib")
+
+	// Types and templates that exist in both sources are taken from the
+	// source that is earlier on the search path.
+	if src, err := r.TypeSource("bin"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if src.Path != path.Join(root, "extra") {
+		t.Errorf("expected 'bin' from BAKE_PATH, got '%s'", src.Name)
+	}
+
+	if src, err := r.TypeSource("base"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if src.Name != "bake" {
+		t.Errorf("expected 'base' from bake, got '%s'", src.Name)
+	}
+
+	expectTemplate(t, r, path.Join(root, "recipes/x/templates"))
+	expected := path.Join(root, "extra/x/templates/{ProjectName}/b")
+	if actual, err := r.Template("{ProjectName}/b"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if expected != actual {
+		t.Errorf("expected '%s', got '%s'", expected, actual)
+	}
+}
+
+func TestForUnknownLang(t *testing.T) {
+	root := tempBake(t)
+	defer os.RemoveAll(root)
+
+	mkdirs(t, root, "recipes/x/types")
+
+	if _, err := For("y"); err == nil {
+		t.Errorf("expected error loading unknown language")
+	}
+}
+
+func TestTypeFileUnknownType(t *testing.T) {
+	root := tempBake(t)
+	defer os.RemoveAll(root)
+
+	mkfiles(t, root, "recipes/x/types/base")
+
+	r, err := For("x")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := r.TypeFile("bin"); err == nil {
+		t.Errorf("expected error getting unknown type")
+	}
+}
+
+func TestTypeInfos(t *testing.T) {
+	root := tempBake(t)
+	defer os.RemoveAll(root)
+
+	mkdirs(t, root, "recipes/x/types/lib")
+	mkfiles(t, root, "recipes/x/types/base", "extra/x/types/base")
+	files := map[string]string{
+		"recipes/x/types/bin":      "Runs\nrequires: make\nconflicts: lib\n",
+		"recipes/x/types/bin.fmt":  "Runs\n",
+		"recipes/x/types/.make.sw": "Builds\n",
+		"recipes/x/types/README":   "Types\n",
+		"extra/x/types/make":       "Builds",
+	}
+	for file, content := range files {
+		mkdirs(t, root, path.Dir(file))
+		err := ioutil.WriteFile(path.Join(root, file), []byte(content), 0666)
+		if err != nil {
+			t.Fatalf("couldn't create '%s': %v", file, err)
+		}
+	}
+	os.Setenv("BAKE_PATH", path.Join(root, "extra"))
+
+	r, err := For("x")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectTypes(t, r, "bin", "make")
+
+	infos, err := r.TypeInfos()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	bin, build := infos[0], infos[1]
+	if bin.Descr != "Runs" || build.Descr != "Builds" {
+		t.Errorf("expected descriptions 'Runs' and 'Builds', got '%s' and "+
+			"'%s'", bin.Descr, build.Descr)
+	}
+	expectStrs(t, "requires", []string{"make"}, bin.Requires)
+	expectStrs(t, "conflicts", []string{"lib"}, bin.Conflicts)
+	if bin.Source.Name != "bake" || build.Source.Path != path.Join(root,
+		"extra") {
+
+		t.Errorf("expected 'bin' from bake and 'make' from BAKE_PATH, got "+
+			"'%s' and '%s'", bin.Source.Name, build.Source.Name)
+	}
+
+	for _, name := range []string{"bin.fmt", "README", "../x/types/bin", ""} {
+		if _, err := r.TypeInfo(name); err == nil {
+			t.Errorf("expected error getting type '%s'", name)
+		}
+	}
+}
+
+func TestPartial(t *testing.T) {
+	root := tempBake(t)
+	defer os.RemoveAll(root)
+
+	mkfiles(t, root,
+		"recipes/x/types/base",
+		"recipes/x/partials/a",
+		"recipes/x/partials/b",
+		"recipes/x/partials/make/c",
+		"extra/x/types/bin",
+		"extra/x/partials/b",
+	)
+	os.Setenv("BAKE_PATH", path.Join(root, "extra"))
+
+	r, err := For("x")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"a":      "recipes/x/partials/a",
+		"b":      "extra/x/partials/b",
+		"make/c": "recipes/x/partials/make/c",
+	}
+	for name, rel := range expected {
+		p := path.Join(root, rel)
+		if actual, err := r.Partial(name); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		} else if p != actual {
+			t.Errorf("expected '%s', got '%s'", p, actual)
+		}
+	}
+
+	for _, name := range []string{"d", "make", "../partials/a"} {
+		if _, err := r.Partial(name); err == nil {
+			t.Errorf("expected error getting partial '%s'", name)
+		}
+	}
+}
+
+func TestDelims(t *testing.T) {
+	root := tempBake(t)
+	defer os.RemoveAll(root)
+
+	mkfiles(t, root,
+		"recipes/x/types/base",
+		"templates/y/base",
+		"templates/y/bin",
+		"recipes/z/types/base",
+	)
+	files := map[string]string{
+		"recipes/x/delims":   "<% %>\n",
+		"templates/y/delims": "[[ ]]\n",
+		"recipes/z/delims":   "<%\n",
+	}
+	for file, content := range files {
+		err := ioutil.WriteFile(path.Join(root, file), []byte(content), 0666)
+		if err != nil {
+			t.Fatalf("couldn't create '%s': %v", file, err)
+		}
+	}
+
+	expected := map[string]template.Delims{
+		"x": {Left: "<%", Right: "%>"},
+		"y": {Left: "[[", Right: "]]"},
+	}
+	for lang, delims := range expected {
+		r, err := For(lang)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if actual, err := r.Delims(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		} else if actual != delims {
+			t.Errorf("expected %v for %s, got %v", delims, lang, actual)
+		}
+	}
+
+	// The delimiters file of a split recipe isn't a type.
+	r, _ := For("y")
+	expectTypes(t, r, "bin")
+
+	r, err := For("z")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := r.Delims(); err == nil {
+		t.Errorf("expected error reading a single delimiter")
+	}
+
+	os.Remove(path.Join(root, "recipes/x/delims"))
+	r, _ = For("x")
+	if d, err := r.Delims(); err != nil || d != template.DefaultDelims {
+		t.Errorf("expected the default delimiters, got %v (%v)", d, err)
+	}
+}
+
+func TestTestScripts(t *testing.T) {
+	root := tempBake(t)
+	defer os.RemoveAll(root)
+
+	mkdirs(t, root, "recipes/x/tests/dir")
+	mkfiles(t, root,
+		"recipes/x/types/base",
+		"recipes/x/types/bin",
+		"recipes/x/types/make",
+		"recipes/x/tests/base",
+		"recipes/x/tests/make_bin",
+		"recipes/x/tests/.fmt",
+		"recipes/x/tests/.DS_Store",
+		"recipes/x/tests/bin.fmt",
+		"extra/x/types/base",
+		"extra/x/tests/bin",
+		"extra/x/tests/make_bin",
+	)
+	os.Setenv("BAKE_PATH", path.Join(root, "extra"))
+
+	r, err := For("x")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	scripts, err := r.TestScripts()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []test.Script{
+		{Types: []string{"base"},
+			Path: path.Join(root, "recipes/x/tests/base")},
+		{Types: []string{"bin"}, Path: path.Join(root, "extra/x/tests/bin")},
+		{Types: []string{"make", "bin"},
+			Path: path.Join(root, "extra/x/tests/make_bin")},
+	}
+	if !reflect.DeepEqual(scripts, expected) {
+		t.Errorf("expected scripts %v, got %v", expected, scripts)
+	}
+
+	mkfiles(t, root, "recipes/x/tests/bin_lib")
+	if _, err := r.TestScripts(); err == nil {
+		t.Errorf("expected error for a script for an unknown type")
+	}
+}
+
+func TestIsTypeName(t *testing.T) {
+	for _, name := range []string{"bin", "my-type", "lib2"} {
+		if !isTypeName(name) {
+			t.Errorf("expected '%s' to be a type name", name)
+		}
+	}
+	for _, name := range []string{"my_type", "Bin", "2lib", "-bin", "",
+		"delims", "vars"} {
+		if isTypeName(name) {
+			t.Errorf("expected '%s' not to be a type name", name)
+		}
+	}
+}
@@ -9,15 +9,23 @@ package test
 // "testing", one "runs" a test.
 
 import (
-	"fmt"
 	"io/ioutil"
-	"path"
 	"strings"
 )
 
-const (
-	testDirName = "tests"
-)
+// scriptTypeSep separates the types in the name of a test script.
+const scriptTypeSep = "_"
+
+// A Script is a test script for a set of bake project types.
+type Script struct {
+	Types []string
+	Path  string
+}
+
+// ScriptTypes returns the types that the test script named `name` is for.
+func ScriptTypes(name string) []string {
+	return strings.Split(name, scriptTypeSep)
+}
 
 // A typeTestGroup is a collection of tests for a set of bake project types.
 //
@@ -37,36 +45,22 @@ func (g *typeTestGroup) Tests() []*typeTest {
 	return g.tests
 }
 
-// Tests a recipe and returns true if the test succeeded.
-func TestRecipe(lang string, recpDirPath string) (bool, error) {
-	testDirPath := path.Join(recpDirPath, testDirName)
-
-	typeTestScripts, err := ioutil.ReadDir(testDirPath)
-	if err != nil {
-		return false, err
-	}
-
-	groups := make([]*typeTestGroup, 0, len(typeTestScripts))
-	for _, typeTestScript := range typeTestScripts {
-		if typeTestScript.IsDir() {
-			fmt.Printf("unexpected dir '%s' in '%s', skipping...",
-				typeTestScript.Name(), testDirPath)
-			continue
-		}
-
-		typeTestScriptName := typeTestScript.Name()
-
-		typeTestScriptPath := path.Join(testDirPath, typeTestScriptName)
-
-		typeTests, err := readTypeTestScript(typeTestScriptPath)
+// A VarsFunc returns the variables that the project named `name` of the types
+// `types` is generated with, which the actions of its tests can refer to.
+type VarsFunc func(name string, types []string) (map[string]string, error)
+
+// Tests a recipe using the test scripts `scripts` and returns true if the test
+// succeeded. The actions of the tests are given the variables returned by
+// `vars` for the project that they test.
+func TestRecipe(lang string, scripts []Script, vars VarsFunc) (bool, error) {
+	var err error
+	var groups []*typeTestGroup
+	for _, script := range scripts {
+		typeTests, err := readTypeTestScript(script.Path)
 		if err != nil {
 			return false, err
 		}
-
-		groups = append(groups, &typeTestGroup{
-			strings.Split(typeTestScriptName, "_"),
-			typeTests,
-		})
+		groups = append(groups, &typeTestGroup{script.Types, typeTests})
 	}
 
 	var tempDir string
@@ -76,7 +70,7 @@ func TestRecipe(lang string, recpDirPath string) (bool, error) {
 
 	passed := true
 	for _, group := range groups {
-		passed_, err := runTypeTestGroup(lang, tempDir, group)
+		passed_, err := runTypeTestGroup(lang, tempDir, group, vars)
 		if err != nil {
 			return false, err
 		}
@@ -188,24 +188,25 @@ func parseTestAction(actionSpecifier rune, cmd string) testAction {
 	return action
 }
 
-func runTypeTestGroup(lang string, testDirPath string,
-	group *typeTestGroup) (passed bool, err error) {
+func runTypeTestGroup(lang string, testDirPath string, group *typeTestGroup,
+	varsFor VarsFunc) (passed bool, err error) {
 
 	passed = true
 
-	typeTestDirName := strings.Join(group.Types(), "_")
+	typeTestDirName := strings.Join(group.Types(), scriptTypeSep)
 	typeTestDirPath := path.Join(testDirPath, typeTestDirName)
 	if err = os.Mkdir(typeTestDirPath, testDirPerm); err != nil {
 		return
 	}
 
 	projName := "Project"
+	vars, err := varsFor(projName, group.Types())
+	if err != nil {
+		return
+	}
 	for _, test := range group.Tests() {
 		for _, action := range test.actions() {
-			action.AddVars(map[string]string{
-				"ProjectName":      projName,
-				"ProjectNameLower": strings.ToLower(projName),
-			})
+			action.AddVars(vars)
 		}
 	}
 
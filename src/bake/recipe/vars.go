@@ -0,0 +1,249 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package recipe
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const (
+	varsFile = "vars" // The recipe file declaring the derived variables
+
+	varExprSep = "=" // Separates a derived variable from its expression
+	ruleKeySep = ":" // Separates the key of a rule from its value
+)
+
+// The keys of the rules that validate derived variables.
+const (
+	matchesKey = "matches"
+	isKey      = "is"
+)
+
+// A DerivedVar is a variable that a recipe computes from the variables given
+// to bake, such as a package name that's valid in the recipe's language.
+type DerivedVar struct {
+	Name string
+	Expr string // The template that the value of the variable expands from
+
+	matches []pattern
+	is      []string
+}
+
+// A pattern is a regular expression that the whole of a value must match.
+type pattern struct {
+	src string
+	re  *regexp.Regexp
+}
+
+// The kinds of name that the value of a derived variable can be checked to
+// be, and the functions that check them.
+var nameKinds = map[string]func(string) bool{
+	"go-identifier":   isGoIdent,
+	"java-identifier": isJavaIdent,
+	"java-package":    isJavaPackage,
+	"python-module":   isPythonModule,
+}
+
+// Validate returns an error if `val` breaks any of the rules of `v`.
+func (v *DerivedVar) Validate(val string) error {
+	for _, p := range v.matches {
+		if !p.re.MatchString(val) {
+			return fmt.Errorf("%s '%s' doesn't match '%s'", v.Name, val,
+				p.src)
+		}
+	}
+	for _, kind := range v.is {
+		if !nameKinds[kind](val) {
+			return fmt.Errorf("%s '%s' isn't a valid %s", v.Name, val,
+				strings.Replace(kind, "-", " ", -1))
+		}
+	}
+	return nil
+}
+
+// DerivedVars returns the derived variables declared by the first layer of `r`
+// that has a variables file, in the order that they're declared. Each
+// declaration is a line of the form `Name = expression`, followed by indented
+// rules of the form `matches: regexp` or `is: kind`.
+func (r *recipe) DerivedVars() ([]*DerivedVar, error) {
+	for _, l := range r.layers {
+		if isFile(l.vars) {
+			return parseVarsFile(l.vars)
+		}
+	}
+	return nil, nil
+}
+
+func parseVarsFile(fpath string) ([]*DerivedVar, error) {
+	file, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	vars, err := readVars(bufio.NewScanner(file))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fpath, err)
+	}
+	return vars, nil
+}
+
+func readVars(in *bufio.Scanner) ([]*DerivedVar, error) {
+	var vars []*DerivedVar
+	seen := map[string]bool{}
+	for n := 1; in.Scan(); n++ {
+		line := in.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if line[0] == ' ' || line[0] == '\t' {
+			if len(vars) == 0 {
+				return nil, fmt.Errorf("line %d: rule before any "+
+					"variable", n)
+			}
+			err := vars[len(vars)-1].addRule(strings.TrimSpace(line))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			continue
+		}
+
+		parts := strings.SplitN(line, varExprSep, 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: expected '%s' after the "+
+				"name of the variable", n, varExprSep)
+		}
+
+		name := strings.TrimSpace(parts[0])
+		if !isVarName(name) {
+			return nil, fmt.Errorf("line %d: '%s' isn't a valid variable "+
+				"name", n, name)
+		} else if seen[name] {
+			return nil, fmt.Errorf("line %d: '%s' is declared more than "+
+				"once", n, name)
+		}
+		seen[name] = true
+
+		expr := strings.TrimSpace(parts[1])
+		vars = append(vars, &DerivedVar{Name: name, Expr: expr})
+	}
+	return vars, in.Err()
+}
+
+// addRule adds the rule `rule`, of the form `key: value`, to `v`.
+func (v *DerivedVar) addRule(rule string) error {
+	parts := strings.SplitN(rule, ruleKeySep, 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("expected '%s' after the key of '%s'", ruleKeySep,
+			rule)
+	}
+	key, val := parts[0], strings.TrimSpace(parts[1])
+
+	switch key {
+	case matchesKey:
+		re, err := regexp.Compile("^(?:" + val + ")$")
+		if err != nil {
+			return err
+		}
+		v.matches = append(v.matches, pattern{val, re})
+	case isKey:
+		if _, ok := nameKinds[val]; !ok {
+			return fmt.Errorf("'%s' isn't a kind of name", val)
+		}
+		v.is = append(v.is, val)
+	default:
+		return fmt.Errorf("unknown rule '%s'", key)
+	}
+	return nil
+}
+
+// isVarName returns true if `s` can name a variable, which starts with an upper
+// case letter, as the names that start with lower case letters are types.
+func isVarName(s string) bool {
+	for i, r := range s {
+		if i == 0 && !unicode.IsUpper(r) {
+			return false
+		} else if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// isIdent returns true if `s` is an identifier in a language whose identifiers
+// are made of letters, digits and the runes in `extra`, don't start with a
+// digit and aren't in `keywords`.
+func isIdent(s string, extra string, keywords map[string]bool) bool {
+	if s == "" || keywords[s] {
+		return false
+	}
+	for i, r := range s {
+		valid := unicode.IsLetter(r) || strings.ContainsRune(extra, r) ||
+			i > 0 && unicode.IsDigit(r)
+		if !valid {
+			return false
+		}
+	}
+	return true
+}
+
+var goKeywords = setOf("break", "case", "chan", "const", "continue",
+	"default", "defer", "else", "fallthrough", "for", "func", "go", "goto",
+	"if", "import", "interface", "map", "package", "range", "return",
+	"select", "struct", "switch", "type", "var")
+
+func isGoIdent(s string) bool {
+	return isIdent(s, "_", goKeywords)
+}
+
+var javaKeywords = setOf("abstract", "assert", "boolean", "break", "byte",
+	"case", "catch", "char", "class", "const", "continue", "default", "do",
+	"double", "else", "enum", "extends", "final", "finally", "float", "for",
+	"goto", "if", "implements", "import", "instanceof", "int", "interface",
+	"long", "native", "new", "package", "private", "protected", "public",
+	"return", "short", "static", "strictfp", "super", "switch",
+	"synchronized", "this", "throw", "throws", "transient", "try", "void",
+	"volatile", "while", "true", "false", "null")
+
+func isJavaIdent(s string) bool {
+	return isIdent(s, "_$", javaKeywords)
+}
+
+// isJavaPackage returns true if `s` is a Java package name, which is made of
+// identifiers separated by dots, such as `com.example.project`.
+func isJavaPackage(s string) bool {
+	for _, ident := range strings.Split(s, ".") {
+		if !isJavaIdent(ident) {
+			return false
+		}
+	}
+	return true
+}
+
+var pythonKeywords = setOf("False", "None", "True", "and", "as", "assert",
+	"async", "await", "break", "class", "continue", "def", "del", "elif",
+	"else", "except", "finally", "for", "from", "global", "if", "import",
+	"in", "is", "lambda", "nonlocal", "not", "or", "pass", "raise", "return",
+	"try", "while", "with", "yield")
+
+// isPythonModule returns true if `s` is a Python module name that follows PEP
+// 8, which asks for short, all lower case names.
+func isPythonModule(s string) bool {
+	return isIdent(s, "_", pythonKeywords) && strings.ToLower(s) == s
+}
+
+func setOf(strs ...string) map[string]bool {
+	set := make(map[string]bool, len(strs))
+	for _, s := range strs {
+		set[s] = true
+	}
+	return set
+}
@@ -0,0 +1,128 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package recipe
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestDerivedVars(t *testing.T) {
+	root := tempBake(t)
+	defer os.RemoveAll(root)
+
+	mkfiles(t, root, "recipes/x/types/base", "templates/y/base")
+	src := "" +
+		"Package = {ProjectName|camel|lower}\n" +
+		"\tis: go-identifier\n" +
+		"\n" +
+		"Module = github.com/{Owner|kebab}\n" +
+		"    matches: [a-z.]+/[a-z-]+\n"
+	for _, file := range []string{"recipes/x/vars", "templates/y/vars"} {
+		err := ioutil.WriteFile(path.Join(root, file), []byte(src), 0666)
+		if err != nil {
+			t.Fatalf("couldn't create '%s': %v", file, err)
+		}
+	}
+
+	for _, lang := range []string{"x", "y"} {
+		r, err := For(lang)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		vars, err := r.DerivedVars()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		} else if len(vars) != 2 {
+			t.Fatalf("expected 2 variables, got %d", len(vars))
+		}
+		if vars[0].Name != "Package" ||
+			vars[0].Expr != "{ProjectName|camel|lower}" {
+
+			t.Errorf("expected Package, got %s = %s", vars[0].Name,
+				vars[0].Expr)
+		}
+		if vars[1].Name != "Module" {
+			t.Errorf("expected Module, got %s", vars[1].Name)
+		}
+	}
+
+	// The variables file of a split recipe isn't a type.
+	r, _ := For("y")
+	expectTypes(t, r)
+
+	mkfiles(t, root, "recipes/z/types/base")
+	r, _ = For("z")
+	if vars, err := r.DerivedVars(); err != nil || vars != nil {
+		t.Errorf("expected no derived variables, got %v (%v)", vars, err)
+	}
+}
+
+func readVarsStr(src string) ([]*DerivedVar, error) {
+	return readVars(bufio.NewScanner(strings.NewReader(src)))
+}
+
+func TestReadBadVars(t *testing.T) {
+	for _, src := range []string{
+		"\tis: go-identifier\n",
+		"Package\n",
+		"package = x\n",
+		"Pack age = x\n",
+		"X = a\nX = b\n",
+		"X = a\n\tis go-identifier\n",
+		"X = a\n\tis: cobol-identifier\n",
+		"X = a\n\tmatches: [a-\n",
+		"X = a\n\tlooks: nice\n",
+	} {
+		if _, err := readVarsStr(src); err == nil {
+			t.Errorf("expected error reading %q", src)
+		}
+	}
+}
+
+func TestValidateDerivedVar(t *testing.T) {
+	tests := map[string]map[string]bool{
+		"is: go-identifier": {
+			"proj": true, "Proj_2": true, "_": true, "ü": true,
+			"2proj": false, "my-proj": false, "func": false, "": false,
+		},
+		"is: java-package": {
+			"com.example.proj": true, "proj": true, "a$.b_": true,
+			"com..proj": false, "com.example.class": false,
+			"com.2proj": false, ".proj": false,
+		},
+		"is: java-identifier": {
+			"Proj": true, "$": true, "a.b": false, "null": false,
+		},
+		"is: python-module": {
+			"proj": true, "my_proj": true, "MyProj": false,
+			"my-proj": false, "import": false, "1proj": false,
+		},
+		"matches: [a-z]+(-[a-z]+)*": {
+			"my-proj": true, "proj": true, "my-": false, "xproj!": false,
+		},
+	}
+
+	for rule, vals := range tests {
+		vars, err := readVarsStr("X = x\n\t" + rule + "\n")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for val, valid := range vals {
+			err := vars[0].Validate(val)
+			if valid && err != nil {
+				t.Errorf("expected '%s' to pass '%s', got: %v", val,
+					rule, err)
+			} else if !valid && err == nil {
+				t.Errorf("expected '%s' to fail '%s'", val, rule)
+			}
+		}
+	}
+}
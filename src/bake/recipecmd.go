@@ -0,0 +1,117 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bake/recipe/install"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// recipeCmds maps the subcommands of `bake recipe` to the functions that run
+// them with the remaining arguments.
+var recipeCmds = map[string]func(args []string) error{
+	"install": installRecipe,
+	"list":    listRecipes,
+	"remove":  removeRecipe,
+}
+
+func recipeUsage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n"+
+		"  %[1]s recipe install [-l|--language lang] [-f|--force] "+
+		"path-or-archive\n"+
+		"  %[1]s recipe list [--format f]\n"+
+		"  %[1]s recipe remove lang\n", os.Args[0])
+}
+
+// runRecipeCmd runs the `bake recipe` command with the arguments following
+// `recipe` and exits.
+func runRecipeCmd(args []string) {
+	if len(args) == 0 {
+		recipeUsage()
+		os.Exit(2)
+	}
+
+	cmd, ok := recipeCmds[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "'%s' is not a recipe command\n", args[0])
+		recipeUsage()
+		os.Exit(2)
+	}
+
+	if err := cmd(args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	os.Exit(0)
+}
+
+func installRecipe(args []string) error {
+	flags := flag.NewFlagSet("recipe install", flag.ExitOnError)
+	flags.Usage = recipeUsage
+	lang := flags.String("l", "", "Language of a single recipe")
+	force := flags.Bool("f", false, "Replace installed recipes")
+	alias(flags, "l", "language")
+	alias(flags, "f", "force")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		recipeUsage()
+		os.Exit(2)
+	}
+
+	installed, err := install.Install(flags.Arg(0), *lang, *force)
+	if err != nil {
+		return err
+	}
+
+	for _, inst := range installed {
+		fmt.Printf("%s\t%s\n", inst.Lang, inst.Version)
+	}
+	return nil
+}
+
+func listRecipes(args []string) error {
+	flags := flag.NewFlagSet("recipe list", flag.ExitOnError)
+	flags.Usage = recipeUsage
+	addFormatFlag(flags)
+	flags.Parse(args)
+	validateFormat()
+
+	if flags.NArg() != 0 {
+		recipeUsage()
+		os.Exit(2)
+	}
+
+	installed, err := install.List()
+	if err != nil {
+		return err
+	}
+
+	docs := make([]recipeDoc, len(installed))
+	for i, inst := range installed {
+		docs[i] = recipeDoc{inst.Lang, inst.Version, inst.Source}
+	}
+
+	if format == jsonFormat {
+		return printJSON(os.Stdout, struct {
+			Recipes []recipeDoc `json:"recipes"`
+		}{docs})
+	}
+
+	for _, doc := range docs {
+		fmt.Printf("%s\t%s\t%s\n", doc.Lang, doc.Version, doc.Source)
+	}
+	return nil
+}
+
+func removeRecipe(args []string) error {
+	if len(args) != 1 {
+		recipeUsage()
+		os.Exit(2)
+	}
+	return install.Remove(args[0])
+}
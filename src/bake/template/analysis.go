@@ -0,0 +1,153 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package template
+
+import (
+	"sort"
+)
+
+// An Analysis lists the names that a template refers to. Each list is sorted.
+type Analysis struct {
+	// Required holds the variables and lists that are referenced outside of
+	// conditional sections, and so must be in the dictionary that the
+	// template is expanded with.
+	Required []string
+
+	// Optional holds the variables and lists that are only referenced
+	// within conditional sections, or with default values.
+	Optional []string
+
+	// Conds holds the names that are used in conditions.
+	Conds []string
+}
+
+// Analyze returns the names that `t` refers to, including those referred to by
+// the partials it includes, which are loaded with `l`. Partials aren't followed
+// if `l` is nil, and are parsed with the delimiters that `t` was parsed with.
+// The elements and indices bound by loops aren't included.
+func (t *Template) Analyze(l PartialLoader) (*Analysis, error) {
+	a := newAnalyzer(l)
+	a.dict.delims = t.delims
+	if err := analyzeNodes(t.nodes, a); err != nil {
+		return nil, err
+	}
+	return a.analysis(), nil
+}
+
+// Filters returns the sorted names of the filters that `t` applies, not
+// including those applied by the partials it includes.
+func (t *Template) Filters() []string {
+	// Only partials can fail to be analyzed, and they aren't followed.
+	a := newAnalyzer(nil)
+	analyzeNodes(t.nodes, a)
+	return sortedNames(a.filters)
+}
+
+// Merge returns the names that are referred to by `a` or `b`. A variable that's
+// required by either is required by the result.
+func (a *Analysis) Merge(b *Analysis) *Analysis {
+	an := newAnalyzer(nil)
+	an.addAll(an.required, a.Required, b.Required)
+	an.addAll(an.optional, a.Optional, b.Optional)
+	an.addAll(an.conds, a.Conds, b.Conds)
+	return an.analysis()
+}
+
+// Conditional returns the names referred to by `a`, with the required
+// variables made optional, for a template that's only expanded when a
+// condition holds.
+func (a *Analysis) Conditional() *Analysis {
+	an := newAnalyzer(nil)
+	an.addAll(an.optional, a.Required, a.Optional)
+	an.addAll(an.conds, a.Conds)
+	return an.analysis()
+}
+
+// An analyzer records the names referred to by the nodes of a template.
+type analyzer struct {
+	required map[string]bool
+	optional map[string]bool
+	conds    map[string]bool
+	filters  map[string]bool
+
+	depth int            // The number of conditional sections being analyzed
+	bound map[string]int // The names bound by the loops being analyzed
+	dict  *Dict          // Holds the partial loader and partials being analyzed
+}
+
+func newAnalyzer(l PartialLoader) *analyzer {
+	d := &Dict{}
+	d.SetPartialLoader(l)
+	return &analyzer{
+		map[string]bool{},
+		map[string]bool{},
+		map[string]bool{},
+		map[string]bool{},
+		0,
+		map[string]int{},
+		d,
+	}
+}
+
+func analyzeNodes(nodes []node, a *analyzer) error {
+	for _, n := range nodes {
+		if err := n.analyze(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ref records a reference to the variable or list `name`. The reference is
+// optional if it's in a conditional section or if `hasDefault` is true.
+func (a *analyzer) ref(name string, hasDefault bool) {
+	if a.bound[name] > 0 {
+		return
+	}
+	if a.depth > 0 || hasDefault {
+		a.optional[name] = true
+	} else {
+		a.required[name] = true
+	}
+}
+
+// cond records the names used in `c`.
+func (a *analyzer) cond(c condExpr) {
+	names := map[string]bool{}
+	c.addNames(names)
+	for name := range names {
+		if a.bound[name] == 0 {
+			a.conds[name] = true
+		}
+	}
+}
+
+func (a *analyzer) addAll(set map[string]bool, lists ...[]string) {
+	for _, names := range lists {
+		for _, name := range names {
+			set[name] = true
+		}
+	}
+}
+
+func (a *analyzer) analysis() *Analysis {
+	for name := range a.required {
+		delete(a.optional, name)
+	}
+	return &Analysis{
+		sortedNames(a.required),
+		sortedNames(a.optional),
+		sortedNames(a.conds),
+	}
+}
+
+func sortedNames(set map[string]bool) []string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
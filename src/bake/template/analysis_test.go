@@ -0,0 +1,104 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package template
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testAnalyze(t *testing.T, l PartialLoader, src string, expected Analysis) {
+	tmpl, err := Parse("", strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing '%s': %v", src, err)
+	}
+
+	a, err := tmpl.Analyze(l)
+	if err != nil {
+		t.Fatalf("Unexpected error analyzing '%s': %v", src, err)
+	}
+
+	if !reflect.DeepEqual(*a, expected) {
+		t.Errorf("Expected analysis of '%s' to be %+v, got %+v", src,
+			expected, *a)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	none := []string{}
+	tests := map[string]Analysis{
+		"text":            {none, none, none},
+		"{a}{b|upper}{a}": {[]string{"a", "b"}, none, none},
+		"{?x}{a}{:y&!z}{b}{:}{c}{?}": {
+			none,
+			[]string{"a", "b", "c"},
+			[]string{"x", "y", "z"},
+		},
+		"{a:-x}{b:?m}{c:-x}{c}":      {[]string{"b", "c"}, []string{"a"}, none},
+		"{a}{?a}{a}{b}{?}":           {[]string{"a"}, []string{"b"}, []string{"a"}},
+		"{*l:e}{e}{eIndex}{x}{*}{e}": {[]string{"e", "l", "x"}, none, none},
+		"{?c}{*l:e}{?e}{e}{?}{*}{?}": {none, []string{"l"}, []string{"c"}},
+	}
+
+	for src, expected := range tests {
+		testAnalyze(t, nil, src, expected)
+	}
+}
+
+func TestAnalyzePartial(t *testing.T) {
+	d, dir := partialDict(t, nil, map[string]string{
+		"p":    "{a}{?b}{c}{?}",
+		"loop": "{>loop}",
+	})
+	defer os.RemoveAll(dir)
+
+	none := []string{}
+	testAnalyze(t, d.partials, "{>p}",
+		Analysis{[]string{"a"}, []string{"c"}, []string{"b"}})
+	testAnalyze(t, d.partials, "{?x}{>p}{?}",
+		Analysis{none, []string{"a", "c"}, []string{"b", "x"}})
+	testAnalyze(t, nil, "{>p}", Analysis{none, none, none})
+
+	tmpl, err := Parse("", strings.NewReader("{>loop}"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err = tmpl.Analyze(d.partials); err == nil {
+		t.Errorf("Expected error analyzing a partial that includes itself")
+	}
+}
+
+func TestAnalysisMerge(t *testing.T) {
+	a := &Analysis{[]string{"a"}, []string{"b", "c"}, []string{"x"}}
+	b := &Analysis{[]string{"b"}, []string{"d"}, []string{"y"}}
+
+	expected := Analysis{
+		[]string{"a", "b"},
+		[]string{"c", "d"},
+		[]string{"x", "y"},
+	}
+	if m := a.Merge(b); !reflect.DeepEqual(*m, expected) {
+		t.Errorf("Expected merge to be %+v, got %+v", expected, *m)
+	}
+
+	expected = Analysis{[]string{}, []string{"a", "b", "c"}, []string{"x"}}
+	if c := a.Conditional(); !reflect.DeepEqual(*c, expected) {
+		t.Errorf("Expected conditional to be %+v, got %+v", expected, *c)
+	}
+}
+
+func TestCondNames(t *testing.T) {
+	c, err := ParseCond("!b&(a|B>=1)")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{"B", "a", "b"}
+	if names := c.Names(); !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected names of '%s' to be %v, got %v", c, expected,
+			names)
+	}
+}
@@ -0,0 +1,349 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package template
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	notOp    = '!' // Inverts the conditional following it
+	andOp    = '&' // Joins two conditionals that must both be true
+	orOp     = '|' // Joins two conditionals, either of which must be true
+	lGroupOp = '(' // Begins a parenthesized conditional
+	rGroupOp = ')' // Ends a parenthesized conditional
+
+	eqOp    = '=' // Compares a variable with values that it must equal
+	ltOp    = '<' // Compares a variable with an earlier version
+	gtOp    = '>' // Compares a variable with a later version
+	valsSep = ',' // Separates the values that a variable is compared with
+)
+
+// A Cond is a conditional, as used to label conditional sections. It's a
+// variable name, a comparison of a variable with values, a bang (`!`) followed
+// by a conditional, or two conditionals combined by `&` or `|`, optionally in
+// parentheses. "Not" has the highest precedence, followed by "or", followed by
+// "and".
+type Cond struct {
+	src  string
+	expr condExpr
+}
+
+type condExpr interface {
+	eval(d *Dict) bool
+
+	// addNames adds the variable names used in the condition to `names`.
+	addNames(names map[string]bool)
+}
+
+type varCond string
+
+func (c varCond) eval(d *Dict) bool {
+	return d.Has(string(c))
+}
+
+func (c varCond) addNames(names map[string]bool) {
+	names[string(c)] = true
+}
+
+// A cmpCond compares the value of a variable with values, using one of the
+// comparison operators, which are "=", "!=", "<", "<=", ">" and ">=".
+type cmpCond struct {
+	name string
+	op   string
+	vals []string
+}
+
+func (c cmpCond) eval(d *Dict) bool {
+	val, ok := d.Get(c.name)
+	if c.op == "!=" {
+		return !cmpCond{c.name, "=", c.vals}.eval(d)
+	} else if !ok {
+		return false
+	}
+
+	if c.op == "=" {
+		for _, v := range c.vals {
+			if val == v {
+				return true
+			}
+		}
+		return false
+	}
+
+	cmp := compareVersions(val, c.vals[0])
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	}
+	return cmp >= 0
+}
+
+func (c cmpCond) addNames(names map[string]bool) {
+	names[c.name] = true
+}
+
+type notCond struct {
+	c condExpr
+}
+
+func (c notCond) eval(d *Dict) bool {
+	return !c.c.eval(d)
+}
+
+func (c notCond) addNames(names map[string]bool) {
+	c.c.addNames(names)
+}
+
+type andCond struct {
+	l, r condExpr
+}
+
+func (c andCond) eval(d *Dict) bool {
+	return c.l.eval(d) && c.r.eval(d)
+}
+
+func (c andCond) addNames(names map[string]bool) {
+	c.l.addNames(names)
+	c.r.addNames(names)
+}
+
+type orCond struct {
+	l, r condExpr
+}
+
+func (c orCond) eval(d *Dict) bool {
+	return c.l.eval(d) || c.r.eval(d)
+}
+
+func (c orCond) addNames(names map[string]bool) {
+	c.l.addNames(names)
+	c.r.addNames(names)
+}
+
+// ParseCond parses the conditional `src`, which is written as it would be
+// between `{?` and `}` in a template.
+func ParseCond(src string) (*Cond, error) {
+	in := newSource("", src, DefaultDelims)
+
+	expr, err := readCond(in)
+	if err != nil {
+		return nil, err
+	}
+	if !isEOF(in) {
+		pos := in.Pos()
+		return nil, posErr(pos, "Unexpected character '%c'", in.Next())
+	}
+
+	return &Cond{src, expr}, nil
+}
+
+// Eval returns true if `c` holds in `d`.
+func (c *Cond) Eval(d *Dict) bool {
+	return c.expr.eval(d)
+}
+
+// Names returns the sorted names of the variables used in `c`.
+func (c *Cond) Names() []string {
+	names := map[string]bool{}
+	c.expr.addNames(names)
+	return sortedNames(names)
+}
+
+// String returns the source of `c`.
+func (c *Cond) String() string {
+	return c.src
+}
+
+// readCond reads a conditional from `in`, stopping at the first rune that
+// can't continue it.
+func readCond(in *source) (condExpr, error) {
+	l, err := readOrCond(in)
+	for err == nil && in.Peek() == andOp {
+		in.Next()
+		var r condExpr
+		if r, err = readOrCond(in); err == nil {
+			l = andCond{l, r}
+		}
+	}
+	return l, err
+}
+
+func readOrCond(in *source) (condExpr, error) {
+	l, err := readNotCond(in)
+	for err == nil && in.Peek() == orOp {
+		in.Next()
+		var r condExpr
+		if r, err = readNotCond(in); err == nil {
+			l = orCond{l, r}
+		}
+	}
+	return l, err
+}
+
+func readNotCond(in *source) (condExpr, error) {
+	switch in.Peek() {
+	case notOp:
+		in.Next()
+		c, err := readNotCond(in)
+		return notCond{c}, err
+	case lGroupOp:
+		in.Next()
+		c, err := readCond(in)
+		if err == nil {
+			err = match(in, rGroupOp)
+		}
+		return c, err
+	}
+
+	var buf bytes.Buffer
+	for isVarRune(in.Peek()) {
+		buf.WriteRune(in.Next())
+	}
+
+	if buf.Len() == 0 {
+		if isEOF(in) {
+			return nil, parseErr(in, "Expected variable, got EOF")
+		}
+		pos := in.Pos()
+		return nil, posErr(pos, "Expected variable, got '%c'", in.Next())
+	}
+	name := buf.String()
+
+	if in.atRight() {
+		return varCond(name), nil
+	}
+	switch in.Peek() {
+	case eqOp, notOp, ltOp, gtOp:
+		return readCmpCond(in, name)
+	}
+	return varCond(name), nil
+}
+
+// readCmpCond reads the comparison of the variable `name` from `in`, from its
+// operator onwards. Only variables, whose names start with an upper case
+// letter, can be compared, as the other names are types, which are never given
+// values.
+func readCmpCond(in *source, name string) (condExpr, error) {
+	pos := in.Pos()
+	op := string(in.Next())
+	if op == string(notOp) {
+		if err := match(in, eqOp); err != nil {
+			return nil, err
+		}
+		op += string(eqOp)
+	} else if op != string(eqOp) && in.Peek() == eqOp {
+		op += string(in.Next())
+	}
+
+	if !unicode.IsUpper([]rune(name)[0]) {
+		return nil, posErr(pos, "Only variables can be compared, and "+
+			"'%s' doesn't start with an upper case letter", name)
+	}
+
+	var vals []string
+	for {
+		val, err := readCmpVal(in)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, val)
+
+		if in.Peek() != valsSep {
+			break
+		}
+		in.Next()
+	}
+
+	if op != "=" && op != "!=" {
+		if len(vals) > 1 {
+			return nil, parseErr(in, "'%s' can only be compared with "+
+				"one version", op)
+		}
+		if !isVersion(vals[0]) {
+			return nil, parseErr(in, "'%s' isn't a version", vals[0])
+		}
+	}
+
+	return cmpCond{name, op, vals}, nil
+}
+
+// Read a value that a variable is compared with from `in`.
+func readCmpVal(in *source) (string, error) {
+	var buf bytes.Buffer
+	for isCmpValRune(in.Peek()) && !in.atRight() {
+		buf.WriteRune(in.Next())
+	}
+
+	if buf.Len() > 0 {
+		return buf.String(), nil
+	} else if isEOF(in) {
+		return "", parseErr(in, "Expected value, got EOF")
+	}
+	pos := in.Pos()
+	return "", posErr(pos, "Expected value, got '%c'", in.Next())
+}
+
+// Is `r` legal in a value that a variable is compared with?
+func isCmpValRune(r rune) bool {
+	return isVarRune(r) || strings.ContainsRune("._-+", r)
+}
+
+// isVersion returns true if `v` is a version, which is a list of numbers
+// separated by dots.
+func isVersion(v string) bool {
+	for _, part := range strings.Split(v, ".") {
+		if part == "" {
+			return false
+		}
+		for _, r := range part {
+			if r < '0' || '9' < r {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// compareVersions returns a negative number if the version `a` comes before
+// `b`, a positive number if it comes after `b`, and 0 if they're the same.
+// Versions are compared a part at a time, where missing parts count as 0, so
+// that "1.2" is the same as "1.2.0". A leading "v" is ignored. Parts that
+// aren't numbers come after those that are, and are compared as strings.
+func compareVersions(a, b string) int {
+	as := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for len(as) < len(bs) {
+		as = append(as, "0")
+	}
+	for len(bs) < len(as) {
+		bs = append(bs, "0")
+	}
+
+	for i := range as {
+		x, errX := strconv.Atoi(as[i])
+		y, errY := strconv.Atoi(bs[i])
+		switch {
+		case errX == nil && errY == nil:
+			if x != y {
+				return x - y
+			}
+		case errX == nil:
+			return -1
+		case errY == nil:
+			return 1
+		case as[i] != bs[i]:
+			return strings.Compare(as[i], bs[i])
+		}
+	}
+	return 0
+}
@@ -0,0 +1,174 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/scanner"
+	"unicode"
+)
+
+const (
+	delimsDelim = '=' // Denotes the start/end of a change of delimiters
+	rawDelim    = '%' // Denotes the start/end of a raw block
+)
+
+// Delims are the delimiters that begin and end the directives of a template.
+type Delims struct {
+	Left  string
+	Right string
+}
+
+// DefaultDelims are the delimiters that templates use unless they're changed.
+var DefaultDelims = Delims{string(lDelim), string(rDelim)}
+
+// Validate returns an error if `d` can't be used as delimiters. Delimiters
+// can't be empty or contain whitespace or `=`, the right delimiter can't start
+// with a character that can continue a directive, and neither delimiter can
+// begin with the other.
+func (d Delims) Validate() error {
+	for _, delim := range []string{d.Left, d.Right} {
+		if delim == "" {
+			return fmt.Errorf("Delimiters can't be empty")
+		}
+		if strings.ContainsRune(delim, delimsDelim) ||
+			strings.IndexFunc(delim, unicode.IsSpace) >= 0 {
+
+			return fmt.Errorf("Delimiter '%s' contains '%c' or whitespace",
+				delim, delimsDelim)
+		}
+	}
+
+	if isCmpValRune([]rune(d.Right)[0]) || isPartialRune([]rune(d.Right)[0]) {
+		return fmt.Errorf("Right delimiter '%s' can't start with '%c'",
+			d.Right, []rune(d.Right)[0])
+	}
+	if strings.HasPrefix(d.Left, d.Right) || strings.HasPrefix(d.Right, d.Left) {
+		return fmt.Errorf("Delimiters '%s' and '%s' overlap", d.Left, d.Right)
+	}
+
+	return nil
+}
+
+// SetDelims sets the delimiters of the templates that are expanded with
+// Expand and ExpandStr.
+func (d *Dict) SetDelims(delims Delims) error {
+	if err := delims.Validate(); err != nil {
+		return err
+	}
+	if delims != d.delims {
+		d.parsed = nil
+	}
+	d.delims = delims
+	return nil
+}
+
+// Delims returns the delimiters of the templates that are expanded with
+// Expand and ExpandStr.
+func (d *Dict) Delims() Delims {
+	if d.delims == (Delims{}) {
+		return DefaultDelims
+	}
+	return d.delims
+}
+
+// A source scans a template, and holds the delimiters of its directives, which
+// may be longer than a single character.
+type source struct {
+	*scanner.Scanner
+	text   string // The whole template
+	delims Delims
+}
+
+func newSource(name, text string, delims Delims) *source {
+	var in scanner.Scanner
+	in.Init(strings.NewReader(text))
+	in.Filename = name
+	return &source{&in, text, delims}
+}
+
+// at returns true if the unread part of `in` starts with `s`.
+func (in *source) at(s string) bool {
+	return strings.HasPrefix(in.text[in.Pos().Offset:], s)
+}
+
+// atRight returns true if the unread part of `in` starts with the right
+// delimiter.
+func (in *source) atRight() bool {
+	return in.at(in.delims.Right)
+}
+
+// skip consumes `s`, which must be at the start of the unread part of `in`.
+func (in *source) skip(s string) {
+	for range s {
+		in.Next()
+	}
+}
+
+// matchRight consumes the right delimiter, and returns an error if it isn't
+// next in `in`.
+func (in *source) matchRight() error {
+	if in.atRight() {
+		in.skip(in.delims.Right)
+		return nil
+	} else if isEOF(in) {
+		return parseErr(in, "Expected '%s', got EOF", in.delims.Right)
+	}
+	return posErr(in.Pos(), "Expected '%s', got %q", in.delims.Right,
+		in.Next())
+}
+
+// Read the delimiters that a `{=left right=}` directive changes to from `in`,
+// after its opening `=`, up to and including the end of the directive.
+func readDelims(in *source) (Delims, error) {
+	var left, right bytes.Buffer
+	end := string(delimsDelim) + in.delims.Right
+
+	for !isEOF(in) && !unicode.IsSpace(in.Peek()) {
+		left.WriteRune(in.Next())
+	}
+	for isBlank(in.Peek()) {
+		in.Next()
+	}
+	for !isEOF(in) && !in.at(end) && !unicode.IsSpace(in.Peek()) {
+		right.WriteRune(in.Next())
+	}
+
+	if !in.at(end) {
+		if isEOF(in) {
+			return Delims{}, parseErr(in, "Expected '%s', got EOF", end)
+		}
+		pos := in.Pos()
+		return Delims{}, posErr(pos, "Expected '%s', got %q", end, in.Next())
+	}
+	pos := in.Pos()
+	in.skip(end)
+
+	d := Delims{left.String(), right.String()}
+	if err := d.Validate(); err != nil {
+		return Delims{}, posErr(pos, "%v", err)
+	}
+	return d, nil
+}
+
+// Read the text of a raw block from `in`, after its opening `{%`, up to and
+// including the `%}` that closes it.
+func readRaw(in *source) (string, error) {
+	pos := in.Pos()
+	end := string(rawDelim) + in.delims.Right
+
+	var buf bytes.Buffer
+	for !in.at(end) {
+		if isEOF(in) {
+			return "", posErr(pos, "Raw block hasn't been closed")
+		}
+		buf.WriteRune(in.Next())
+	}
+	in.skip(end)
+
+	return buf.String(), nil
+}
@@ -0,0 +1,152 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package template
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestExpandSetDelims(t *testing.T) {
+	d := NewDict(map[string]string{"x": "a", "X": "a"})
+	d.SetList("l", []string{"1", "2"})
+	if err := d.SetDelims(Delims{"<%", "%>"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	testExpand(t, d, "func f() { <%x%> }", "func f() { a }")
+	testExpand(t, d, "{x} <%x|upper%>", "{x} A")
+	testExpand(t, d, "<%?x%>{<%x%>}<%:%>none<%?%>", "{a}")
+	testExpand(t, d, "<%?X=a%>yes<%?%>", "yes")
+	testExpand(t, d, "<%*l:e%><%e%>,<%*%>", "1,2,")
+	testExpand(t, d, "<%<%x%>%>", "<%x%>")
+	testExpand(t, d, "<%y:-none%>", "none")
+
+	for _, src := range []string{
+		"<%x",
+		"<%x}",
+		"x%>",
+		"<%%>",
+	} {
+		expandFail(t, d, src)
+	}
+}
+
+func TestExpandDelimsTag(t *testing.T) {
+	d := NewDict(map[string]string{"x": "a"})
+
+	testExpand(t, d, "{=<% %>=}{x}<%x%>", "{x}a")
+	testExpand(t, d, "{=<% %>=}\nint main() { <%x%>; }\n",
+		"int main() { a; }\n")
+	testExpand(t, d, "{x}{=[[ ]]=}[[x]]{x}[[=<% %>=]]<%x%>", "aa{x}a")
+	testExpand(t, d, "{=<% %>=}\n\t<%?x%>\n{}\n\t<%?%>\n", "{}\n")
+
+	for _, src := range []string{
+		"{=<%=}",
+		"{=<% %>}",
+		"{=<% %>",
+		"{=< <=}",
+		"{=<% x=}",
+		"{= %>=}",
+	} {
+		expandFail(t, d, src)
+	}
+}
+
+func TestExpandRaw(t *testing.T) {
+	d := NewDict(map[string]string{"x": "a"})
+
+	testExpand(t, d, "{%{x}%}", "{x}")
+	testExpand(t, d, "{x}{%{{ {?x} }}\n{*%}{x}", "a{{ {?x} }}\n{*a")
+	testExpand(t, d, "{%%}", "")
+	testExpand(t, d, "{=<% %>=}<%%{x} <%x%>%%>", "{x} <%x%>")
+
+	expandFail(t, d, "{%{x}")
+	expandFail(t, d, "{%{x}%")
+}
+
+func TestValidateDelims(t *testing.T) {
+	for _, delims := range []Delims{
+		DefaultDelims,
+		{"<%", "%>"},
+		{"[[", "]]"},
+		{"{{", "}}"},
+	} {
+		if err := delims.Validate(); err != nil {
+			t.Errorf("Unexpected error for %v: %v", delims, err)
+		}
+	}
+
+	for _, delims := range []Delims{
+		{},
+		{"<%", ""},
+		{"<", "<"},
+		{"<%", "<"},
+		{"<% ", "%>"},
+		{"<%", "=>"},
+		{"<%", "a>"},
+		{"<%", "-%>"},
+	} {
+		if err := delims.Validate(); err == nil {
+			t.Errorf("Expected error for %v, got none", delims)
+		}
+	}
+
+	var d Dict
+	if err := d.SetDelims(Delims{"<", ""}); err == nil {
+		t.Errorf("Expected error setting invalid delimiters, got none")
+	}
+	if d.Delims() != DefaultDelims {
+		t.Errorf("Expected default delimiters, got %v", d.Delims())
+	}
+}
+
+func TestParseWith(t *testing.T) {
+	src := "<%?x%>\nline <%x%>\n<%?%>\n<%y\n"
+	_, err := ParseWith("t", strings.NewReader(src), Delims{"<%", "%>"})
+	errs := expectErrors(t, err, 4)
+	if errs[0].Column != 3 {
+		t.Errorf("Expected error at t[4:3], got: %v", errs[0])
+	}
+
+	tmpl, err := ParseWith("t", strings.NewReader(src[:len(src)-5]),
+		Delims{"<%", "%>"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var out bytes.Buffer
+	err = tmpl.Execute(NewDict(map[string]string{"x": "a"}), &out)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	} else if out.String() != "line a\n" {
+		t.Errorf("Expected 'line a\\n', got '%s'", out.String())
+	}
+
+	_, err = ParseWith("t", strings.NewReader(""), Delims{"<", "<"})
+	if err == nil {
+		t.Errorf("Expected error for overlapping delimiters, got none")
+	}
+}
+
+func TestExpandPartialDelims(t *testing.T) {
+	d, dir := partialDict(t,
+		map[string]string{"x": "a"},
+		map[string]string{
+			"p":   "{<%x%>}",
+			"own": "<%=[[ ]]=%>[[x]]<%x%>",
+		},
+	)
+	defer os.RemoveAll(dir)
+	if err := d.SetDelims(Delims{"<%", "%>"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	testExpand(t, d, "<%>p%>", "{a}")
+	testExpand(t, d, "<%>own%><%x%>", "a<%x%>a")
+	testExpand(t, d, "<%=[ ]=%>[>p][x]", "{a}a")
+}
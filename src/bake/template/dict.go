@@ -0,0 +1,111 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package template
+
+// A Dict holds the variables that templates are expanded with, along with the
+// filters that can be applied to them. A variable holds either a string or a
+// list of strings. The zero value is an empty dictionary that only has the
+// built-in filters.
+type Dict struct {
+	vars    map[string]string
+	lists   map[string][]string
+	filters map[string]Filter
+
+	partials  PartialLoader
+	parsed    map[string][]node // The partials that have been parsed, by name
+	including []string          // The partials being expanded, outermost first
+
+	delims   Delims // The delimiters used by Expand, or zero for the defaults
+	escaping string // The escaping mode of substituted values, if any
+	line     string // The output of the current line, which values escape in
+}
+
+// NewDict returns a dictionary holding a copy of `vars`.
+func NewDict(vars map[string]string) *Dict {
+	d := &Dict{}
+	for name, val := range vars {
+		d.Set(name, val)
+	}
+	return d
+}
+
+// Set sets the variable `name` to `val`.
+func (d *Dict) Set(name, val string) {
+	if d.vars == nil {
+		d.vars = map[string]string{}
+	}
+	delete(d.lists, name)
+	d.vars[name] = val
+}
+
+// Get returns the value of the variable `name`, and whether it's set to a
+// string.
+func (d *Dict) Get(name string) (string, bool) {
+	val, ok := d.vars[name]
+	return val, ok
+}
+
+// SetList sets the variable `name` to a copy of `vals`.
+func (d *Dict) SetList(name string, vals []string) {
+	if d.lists == nil {
+		d.lists = map[string][]string{}
+	}
+	delete(d.vars, name)
+	d.lists[name] = append([]string{}, vals...)
+}
+
+// GetList returns the value of the variable `name`, and whether it's set to a
+// list.
+func (d *Dict) GetList(name string) ([]string, bool) {
+	vals, ok := d.lists[name]
+	return vals, ok
+}
+
+// Has returns true if the variable `name` is set to a string or to a list that
+// isn't empty.
+func (d *Dict) Has(name string) bool {
+	_, ok := d.vars[name]
+	return ok || len(d.lists[name]) > 0
+}
+
+// shadow sets the variable `name` to `val`, and returns a function that
+// restores its previous value.
+func (d *Dict) shadow(name, val string) func() {
+	oldVal, isVal := d.vars[name]
+	oldList, isList := d.lists[name]
+	d.Set(name, val)
+
+	return func() {
+		delete(d.vars, name)
+		if isVal {
+			d.vars[name] = oldVal
+		} else if isList {
+			d.lists[name] = oldList
+		}
+	}
+}
+
+// RegisterFilter makes `f` available to the templates expanded with `d` as
+// `name`, replacing any filter already registered as `name`, including the
+// built-in filters.
+func (d *Dict) RegisterFilter(name string, f Filter) {
+	if d.filters == nil {
+		d.filters = map[string]Filter{}
+	}
+	d.filters[name] = f
+}
+
+// Filter returns the filter that the templates expanded with `d` apply as
+// `name`, and whether there is one.
+func (d *Dict) Filter(name string) (Filter, bool) {
+	if f, ok := d.filters[name]; ok {
+		return f, true
+	}
+	if f, ok := builtinFilters[name]; ok {
+		return f, true
+	}
+	f, ok := escapers[name]
+	return f, ok
+}
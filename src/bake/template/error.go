@@ -0,0 +1,120 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package template
+
+import (
+	"fmt"
+	"strings"
+	"text/scanner"
+)
+
+// An Error is an error at a position in a template.
+type Error struct {
+	File    string // The name that the template was parsed with
+	Line    int    // The line of the error, counting from 1
+	Column  int    // The column of the error, counting from 0
+	Msg     string
+	Snippet string // The line of the template containing the error, if known
+}
+
+func posErr(p scanner.Position, msg string, params ...interface{}) error {
+	col := p.Column - 1
+	if col < 0 {
+		col = 0
+	}
+	return &Error{p.Filename, p.Line, col, fmt.Sprintf(msg, params...), ""}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s[%d:%d] %s", e.File, e.Line, e.Column, e.Msg)
+}
+
+// Caret returns the snippet of `e` followed by a line with a caret under the
+// column of the error, or "" if `e` has no snippet.
+func (e *Error) Caret() string {
+	if e.Snippet == "" {
+		return ""
+	}
+
+	// Tabs are kept so that the caret lines up with the snippet however
+	// wide tabs are shown.
+	var indent []rune
+	for i, r := range []rune(e.Snippet) {
+		if i >= e.Column {
+			break
+		}
+		if r != '\t' {
+			r = ' '
+		}
+		indent = append(indent, r)
+	}
+
+	return e.Snippet + "\n" + string(indent) + "^"
+}
+
+// An ErrorList is a list of errors in templates, in the order they were found.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%v (and %d more errors)", l[0], len(l)-1)
+}
+
+// Err returns `l` as an error, or nil if `l` is empty.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// add appends `err` to `l` if it's an error in a template, or a list of them,
+// and returns nil. Any other error is returned, and nil is returned for nil.
+// Errors that are already in `l` aren't added again, so that an error in a loop
+// is only reported once.
+func (l *ErrorList) add(err error) error {
+	switch err := err.(type) {
+	case nil:
+		return nil
+	case *Error:
+		for _, e := range *l {
+			if *e == *err {
+				return nil
+			}
+		}
+		*l = append(*l, err)
+		return nil
+	case ErrorList:
+		for _, e := range err {
+			l.add(e)
+		}
+		return nil
+	}
+	return err
+}
+
+// withSnippets sets the snippet of each error in `err` from `name` that doesn't
+// have one to the line of `src` that it's on.
+func withSnippets(err error, name, src string) error {
+	var errs ErrorList
+	if err := errs.add(err); err != nil {
+		return err
+	}
+
+	lines := strings.Split(src, "\n")
+	for _, e := range errs {
+		if e.File == name && e.Snippet == "" && 0 < e.Line &&
+			e.Line <= len(lines) {
+
+			e.Snippet = strings.TrimSuffix(lines[e.Line-1], "\r")
+		}
+	}
+	return errs.Err()
+}
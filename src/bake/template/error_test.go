@@ -0,0 +1,95 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package template
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// expectErrors checks that `err` is an ErrorList of errors on `lines`.
+func expectErrors(t *testing.T, err error, lines ...int) ErrorList {
+	errs, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("Expected an ErrorList, got %T: %v", err, err)
+	}
+
+	if len(errs) != len(lines) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(lines), len(errs),
+			errs)
+	}
+	for i, e := range errs {
+		if e.Line != lines[i] {
+			t.Errorf("Expected error %d on line %d, got: %v", i, lines[i],
+				e)
+		}
+	}
+	return errs
+}
+
+func TestParseErrors(t *testing.T) {
+	src := "" +
+		"{ProjectName|}\n" +
+		"{?a&}\n" +
+		"ok {x}\n" +
+		"{:}\n" +
+		"{:}\n" +
+		"{?}\n" +
+		"a}b\n" +
+		"{*}\n" +
+		"{*l:e}{?b}{*}\n"
+
+	_, err := Parse("t", strings.NewReader(src))
+	errs := expectErrors(t, err, 1, 2, 5, 7, 8, 9, 10)
+
+	if errs[0].File != "t" || errs[0].Column != 13 {
+		t.Errorf("Expected error at t[1:13], got: %v", errs[0])
+	}
+	if errs[0].Snippet != "{ProjectName|}" {
+		t.Errorf("Expected snippet of line 1, got '%s'", errs[0].Snippet)
+	}
+	if !strings.Contains(errs[1].Msg, "got '}'") {
+		t.Errorf("Expected message about the condition, got: %v", errs[1])
+	}
+	if !strings.HasSuffix(err.Error(), "(and 6 more errors)") {
+		t.Errorf("Expected count of other errors, got: %v", err)
+	}
+}
+
+func TestExecuteErrors(t *testing.T) {
+	src := "{a}\n{*l:e}{e}{b}{*}\n{c|nope}\n{?x}{d|nope}{?}\n{e}\n"
+	tmpl, err := Parse("t", strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	d := NewDict(map[string]string{"e": "E"})
+	d.SetList("l", []string{"1", "2"})
+
+	var out bytes.Buffer
+	err = tmpl.Execute(d, &out)
+	errs := expectErrors(t, err, 3, 4, 1, 2, 3)
+
+	if errs[2].Msg != "Unknown variable 'a'" {
+		t.Errorf("Expected unknown variable 'a', got: %v", errs[2])
+	}
+	if !strings.HasSuffix(out.String(), "\nE\n") {
+		t.Errorf("Expected expansion to carry on past errors, got '%s'",
+			out.String())
+	}
+}
+
+func TestErrorCaret(t *testing.T) {
+	e := &Error{"t", 2, 4, "Oops", "\tab{x"}
+	if c := e.Caret(); c != "\tab{x\n\t   ^" {
+		t.Errorf("Expected caret under 'x', got:\n%s", c)
+	}
+
+	e.Snippet = ""
+	if c := e.Caret(); c != "" {
+		t.Errorf("Expected no caret without a snippet, got:\n%s", c)
+	}
+}
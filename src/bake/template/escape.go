@@ -0,0 +1,182 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package template
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// rawFilter is the filter that stops the value of a variable being escaped.
+const rawFilter = "raw"
+
+// The escapers make a value safe to insert into a quoted context in the
+// language they're named after: a Go interpreted string literal, a JSON or
+// YAML double-quoted string, XML or HTML text or an attribute value, or a
+// single-quoted shell word. The quotes themselves aren't added. Each escaper is
+// also a filter of the same name.
+var escapers = map[string]Filter{
+	"go": func(s string) string {
+		q := strconv.Quote(s)
+		return q[1 : len(q)-1]
+	},
+	"json": escapeJSON,
+	"yaml": escapeJSON, // JSON strings are valid YAML double-quoted strings.
+	"xml":  html.EscapeString,
+	"html": html.EscapeString,
+	"shell": func(s string) string {
+		return strings.Replace(s, "'", `'\''`, -1)
+	},
+}
+
+func escapeJSON(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		// Strings can always be encoded.
+		panic(err)
+	}
+	q := strings.TrimSuffix(buf.String(), "\n")
+	return q[1 : len(q)-1]
+}
+
+// The escaping modes of files with particular extensions.
+var extEscaping = map[string]string{
+	".go":   "go",
+	".json": "json",
+	".yaml": "yaml",
+	".yml":  "yaml",
+	".xml":  "xml",
+	".html": "html",
+	".htm":  "html",
+	".sh":   "shell",
+	".bash": "shell",
+}
+
+// EscapingFor returns the escaping mode of the file at `fpath`, based on its
+// extension, or "" if its values shouldn't be escaped.
+func EscapingFor(fpath string) string {
+	return extEscaping[strings.ToLower(path.Ext(fpath))]
+}
+
+// A quoting describes the string literals of a language, so that values are
+// only escaped where its escaper applies.
+type quoting struct {
+	quote   byte   // Begins and ends the literals that the escaper is for
+	others  string // Begin and end other literals, which aren't escaped
+	escapes string // Begin the literals in which `\` escapes the next character
+	comment string // Begins a comment outside of literals
+}
+
+// The quotings of the escaping modes whose escapers are only for string
+// literals. Values are escaped everywhere in the other modes.
+var quotings = map[string]quoting{
+	"go":    {'"', "`'", "\"'", "//"},
+	"json":  {'"', "", "\"", ""},
+	"yaml":  {'"', "'", "\"", "#"},
+	"shell": {'\'', "\"`", "\"`", "#"},
+}
+
+// inLiteral returns true if the text following `line` is inside one of the
+// string literals that `q` is for.
+func inLiteral(q quoting, line string) bool {
+	var open byte // The quote of the literal that `line` ends in, if any
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case open == 0 && isComment(q.comment, line, i):
+			return false
+		case open == 0 && (c == q.quote || strings.IndexByte(q.others, c) >= 0):
+			open = c
+		case open != 0 && c == '\\' && strings.IndexByte(q.escapes, open) >= 0:
+			i++
+		case c == open:
+			open = 0
+		}
+	}
+	return open == q.quote
+}
+
+// isComment returns true if `comment` begins at index `i` of `line`. A `#`
+// only begins a comment at the start of a word.
+func isComment(comment, line string, i int) bool {
+	if comment == "" || !strings.HasPrefix(line[i:], comment) {
+		return false
+	}
+	return comment != "#" || i == 0 || line[i-1] == ' ' || line[i-1] == '\t'
+}
+
+// SetEscaping makes templates that are expanded with `d` escape the values that
+// they substitute using the escaping mode `mode`, which is one of "go", "json",
+// "yaml", "xml", "html" or "shell", or "" to insert values verbatim. Values are
+// escaped after their filters are applied, unless one of the filters is an
+// escaper or `raw`, in which case the value is left as the filters make it. The
+// values of the "go", "json" and "yaml" modes are only escaped inside
+// double-quoted strings, and those of "shell" inside single-quoted words, so
+// that values in comments and code are inserted verbatim.
+func (d *Dict) SetEscaping(mode string) error {
+	if _, ok := escapers[mode]; !ok && mode != "" {
+		return fmt.Errorf("Unknown escaping mode '%s'", mode)
+	}
+	d.escaping = mode
+	return nil
+}
+
+// Escaping returns the escaping mode of `d`.
+func (d *Dict) Escaping() string {
+	return d.escaping
+}
+
+// escape returns `val` escaped with the escaping mode of `d`, unless `filters`
+// escape it themselves or the current line doesn't need it escaped.
+func (d *Dict) escape(val string, filters []string) string {
+	if d.escaping == "" {
+		return val
+	} else if q, ok := quotings[d.escaping]; ok && !inLiteral(q, d.line) {
+		return val
+	}
+	for _, name := range filters {
+		if _, ok := escapers[name]; ok || name == rawFilter {
+			return val
+		}
+	}
+	return escapers[d.escaping](val)
+}
+
+// write writes `s` to `out` as output of the current line.
+func (d *Dict) write(out *bufio.Writer, s string) error {
+	d.wrote(s)
+	return writeString(out, s)
+}
+
+// writeValue writes the value `val` to `out`. Quotes in values don't begin or
+// end literals, so the value is recorded as a single word.
+func (d *Dict) writeValue(out *bufio.Writer, val string) error {
+	tail := val
+	if i := strings.LastIndex(val, "\n"); i >= 0 {
+		d.line, tail = "", val[i+1:]
+	}
+	if tail != "" {
+		d.line += "_"
+	}
+	return writeString(out, val)
+}
+
+// wrote records that `s` was output, so that the line holds the output
+// following the last newline.
+func (d *Dict) wrote(s string) {
+	if i := strings.LastIndex(s, "\n"); i >= 0 {
+		d.line = s[i+1:]
+	} else {
+		d.line += s
+	}
+}
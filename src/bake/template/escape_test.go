@@ -0,0 +1,132 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package template
+
+import (
+	"os"
+	"testing"
+)
+
+func TestEscapers(t *testing.T) {
+	s := "O'Brien & \"Sons\" <a\\b>\n\tä"
+	tests := map[string]string{
+		"go":    `O'Brien & \"Sons\" <a\\b>\n\tä`,
+		"json":  `O'Brien & \"Sons\" <a\\b>\n\tä`,
+		"yaml":  `O'Brien & \"Sons\" <a\\b>\n\tä`,
+		"xml":   "O&#39;Brien &amp; &#34;Sons&#34; &lt;a\\b&gt;\n\tä",
+		"html":  "O&#39;Brien &amp; &#34;Sons&#34; &lt;a\\b&gt;\n\tä",
+		"shell": "O'\\''Brien & \"Sons\" <a\\b>\n\tä",
+	}
+
+	for mode, expected := range tests {
+		if actual := escapers[mode](s); actual != expected {
+			t.Errorf("Escaping for %s, expected '%s', got '%s'", mode,
+				expected, actual)
+		}
+	}
+}
+
+func TestEscapingFor(t *testing.T) {
+	tests := map[string]string{
+		"src/main.go":     "go",
+		"package.json":    "json",
+		"conf/app.yml":    "yaml",
+		".travis.yaml":    "yaml",
+		"pom.xml":         "xml",
+		"doc/index.HTML":  "html",
+		"install.sh":      "shell",
+		"README.md":       "",
+		"Makefile":        "",
+		"go/README":       "",
+		"script.sh.in":    "",
+		"archive.tar.xml": "xml",
+	}
+
+	for fpath, expected := range tests {
+		if actual := EscapingFor(fpath); actual != expected {
+			t.Errorf("Expected escaping '%s' for '%s', got '%s'", expected,
+				fpath, actual)
+		}
+	}
+}
+
+func TestExpandEscaped(t *testing.T) {
+	d := NewDict(map[string]string{"x": `a "b"`, "q": "O'Brien"})
+	d.SetList("l", []string{`"1"`, "2"})
+
+	testExpand(t, d, `s := "{x}"`, `s := "a "b""`)
+
+	if err := d.SetEscaping("go"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	testExpand(t, d, `s := "{x}"`, `s := "a \"b\""`)
+	testExpand(t, d, `"{x|upper}"`, `"A \"B\""`)
+	testExpand(t, d, `{x|raw}`, `a "b"`)
+	testExpand(t, d, `{x|json}`, `a \"b\"`)
+	testExpand(t, d, `{q|shell}`, `O'\''Brien`)
+	testExpand(t, d, `"{y:-"none"}"`, `"\"none\""`)
+	testExpand(t, d, `"{*l:e}{e},{*}"`, `"\"1\",2,"`)
+
+	if err := d.SetEscaping("nope"); err == nil {
+		t.Errorf("Expected error setting unknown escaping mode, got none")
+	} else if d.Escaping() != "go" {
+		t.Errorf("Expected escaping 'go', got '%s'", d.Escaping())
+	}
+
+	if err := d.SetEscaping(""); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	testExpand(t, d, `s := "{x}"`, `s := "a "b""`)
+	testExpand(t, d, `{q|shell}`, `O'\''Brien`)
+}
+
+func TestExpandEscapedLiterals(t *testing.T) {
+	d := NewDict(map[string]string{"x": `O"Brien`, "q": "O'Brien"})
+
+	tests := map[string][]struct{ tmpl, expected string }{
+		"go": {
+			{`// {x}`, `// O"Brien`},
+			{`s := "{x}" // {x}`, `s := "O\"Brien" // O"Brien`},
+			{`s := "a\"{x}"`, `s := "a\"O\"Brien"`},
+			{"s := `{x}`", "s := `O\"Brien`"},
+			{`r := '"'; s := "{x}"`, `r := '"'; s := "O\"Brien"`},
+			{"s := \"\n{x}\n\"{x}\"", "s := \"\nO\"Brien\n\"O\\\"Brien\""},
+		},
+		"yaml": {
+			{`a: {x} # {x}`, `a: O"Brien # O"Brien`},
+			{`a: "#{x}"`, `a: "#O\"Brien"`},
+		},
+		"shell": {
+			{`echo {q} # {q}`, `echo O'Brien # O'Brien`},
+			{`echo '{q}' "{q}"`, `echo 'O'\''Brien' "O'Brien"`},
+			{`echo a#'{q}'`, `echo a#'O'\''Brien'`},
+		},
+		"html": {
+			{`<p>{x}</p>`, `<p>O&#34;Brien</p>`},
+		},
+	}
+
+	for mode, ts := range tests {
+		if err := d.SetEscaping(mode); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for _, test := range ts {
+			testExpand(t, d, test.tmpl, test.expected)
+		}
+	}
+}
+
+func TestExpandEscapedPartial(t *testing.T) {
+	d, dir := partialDict(t,
+		map[string]string{"x": "<b>"},
+		map[string]string{"p": "<i>{x}</i>"},
+	)
+	defer os.RemoveAll(dir)
+
+	if err := d.SetEscaping("html"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	testExpand(t, d, "<p>{>p}{x|raw}</p>", "<p><i>&lt;b&gt;</i><b></p>")
+}
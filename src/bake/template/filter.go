@@ -0,0 +1,116 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package template
+
+import (
+	"strings"
+	"unicode"
+)
+
+// A Filter transforms the value of a variable, and is applied by following the
+// name of the variable with `|` and the name of the filter, as in
+// `{ProjectName|snake}`.
+type Filter func(string) string
+
+var builtinFilters = map[string]Filter{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"snake": func(s string) string {
+		return strings.ToLower(strings.Join(splitWords(s), "_"))
+	},
+	"kebab": func(s string) string {
+		return strings.ToLower(strings.Join(splitWords(s), "-"))
+	},
+	"camel": camel,
+	"title": func(s string) string {
+		ws := splitWords(s)
+		for i, w := range ws {
+			ws[i] = capitalize(w)
+		}
+		return strings.Join(ws, " ")
+	},
+	"words": func(s string) string {
+		return strings.Join(splitWords(s), " ")
+	},
+	rawFilter: func(s string) string {
+		return s
+	},
+	"path": cleanPath,
+}
+
+// cleanPath returns `s` as a relative path whose elements are separated by
+// `/`. Backslashes are taken to be separators, and empty and `.` elements are
+// removed, so `/github.com\user//proj/` becomes `github.com/user/proj`.
+func cleanPath(s string) string {
+	var elems []string
+	for _, e := range strings.FieldsFunc(s, isSep) {
+		if e != "." {
+			elems = append(elems, e)
+		}
+	}
+	return strings.Join(elems, "/")
+}
+
+func isSep(r rune) bool {
+	return r == '/' || r == '\\'
+}
+
+func camel(s string) string {
+	ws := splitWords(s)
+	for i, w := range ws {
+		if i == 0 {
+			ws[i] = strings.ToLower(w)
+		} else {
+			ws[i] = capitalize(strings.ToLower(w))
+		}
+	}
+	return strings.Join(ws, "")
+}
+
+func capitalize(s string) string {
+	if len(s) == 0 {
+		return s
+	}
+	rs := []rune(s)
+	rs[0] = unicode.ToUpper(rs[0])
+	return string(rs)
+}
+
+// splitWords splits `s` into the words that it's made of. Words are separated by
+// characters that aren't letters or digits, and by changes of case, so that
+// `ProjectName`, `project-name` and `project_name` are all made of the words
+// `project` and `name` (ignoring case). A run of upper case letters is taken to
+// be an acronym, so `HTTPServer` is made of `HTTP` and `Server`. Digits belong
+// to the word that precedes them.
+func splitWords(s string) []string {
+	var words []string
+	var word []rune
+
+	rs := []rune(s)
+	for i, r := range rs {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if len(word) > 0 {
+				words = append(words, string(word))
+				word = nil
+			}
+			continue
+		}
+
+		if len(word) > 0 && unicode.IsUpper(r) {
+			prev := word[len(word)-1]
+			nextIsLower := i+1 < len(rs) && unicode.IsLower(rs[i+1])
+			if !unicode.IsUpper(prev) || nextIsLower {
+				words = append(words, string(word))
+				word = nil
+			}
+		}
+		word = append(word, r)
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+
+	return words
+}
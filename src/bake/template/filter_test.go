@@ -0,0 +1,103 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package template
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitWords(t *testing.T) {
+	tests := map[string][]string{
+		"":              nil,
+		"Project":       {"Project"},
+		"ProjectName":   {"Project", "Name"},
+		"projectName":   {"project", "Name"},
+		"project-name":  {"project", "name"},
+		"project_name":  {"project", "name"},
+		"Project Name":  {"Project", "Name"},
+		"HTTPServer":    {"HTTP", "Server"},
+		"ServeHTTP":     {"Serve", "HTTP"},
+		"Go2Json":       {"Go2", "Json"},
+		"__a--b__":      {"a", "b"},
+		"XMLHttpClient": {"XML", "Http", "Client"},
+	}
+
+	for s, expected := range tests {
+		actual := splitWords(s)
+		if strings.Join(actual, ",") != strings.Join(expected, ",") {
+			t.Errorf("Splitting '%s', expected %q, got %q",
+				s, expected, actual)
+		}
+	}
+}
+
+func TestExpandFilters(t *testing.T) {
+	d := NewDict(map[string]string{"x": "HTTPServerName"})
+
+	testExpand(t, d, "{x|lower}", "httpservername")
+	testExpand(t, d, "{x|upper}", "HTTPSERVERNAME")
+	testExpand(t, d, "{x|snake}", "http_server_name")
+	testExpand(t, d, "{x|kebab}", "http-server-name")
+	testExpand(t, d, "{x|camel}", "httpServerName")
+	testExpand(t, d, "{x|title}", "HTTP Server Name")
+	testExpand(t, d, "{x|words}", "HTTP Server Name")
+	testExpand(t, d, "{x|snake|upper}", "HTTP_SERVER_NAME")
+
+	d = NewDict(map[string]string{"x": "my-project"})
+
+	testExpand(t, d, "{x|camel}", "myProject")
+	testExpand(t, d, "{x|title}", "My Project")
+	testExpand(t, d, "{x|words}", "my project")
+
+	d = NewDict(map[string]string{"x": "/github.com\\me//./proj/"})
+
+	testExpand(t, d, "{x|path}", "github.com/me/proj")
+	testExpand(t, d, "{x|path|lower}", "github.com/me/proj")
+	testExpand(t, d, "{x|raw}", "/github.com\\me//./proj/")
+}
+
+func TestTemplateFilters(t *testing.T) {
+	tmpl, err := Parse("t", strings.NewReader(
+		"{x|path}{?y}{y|lower|path}{:}{*l:e}{e|upper}{*}{?}{z}{>p}"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := "lower,path,upper"
+	if actual := strings.Join(tmpl.Filters(), ","); actual != expected {
+		t.Errorf("Expected filters '%s', got '%s'", expected, actual)
+	}
+}
+
+func TestExpandBadFilter(t *testing.T) {
+	d := NewDict(map[string]string{"x": ""})
+
+	expandFail(t, d, "{x|}")
+	expandFail(t, d, "{x|y}")
+	expandFail(t, d, "{x|lower|}")
+	expandFail(t, d, "{x||lower}")
+
+	// Filters must exist even in sections that aren't expanded.
+	expandFail(t, d, "{?z}{x|y}{?}")
+}
+
+func TestRegisterFilter(t *testing.T) {
+	d := NewDict(map[string]string{"x": "ab"})
+	d.RegisterFilter("twice", func(s string) string {
+		return s + s
+	})
+	d.RegisterFilter("upper", strings.ToLower)
+
+	testExpand(t, d, "{x|twice}", "abab")
+	testExpand(t, d, "{x|twice|upper}", "abab")
+
+	var zero Dict
+	zero.RegisterFilter("twice", func(s string) string {
+		return s + s
+	})
+	zero.Set("x", "a")
+	testExpand(t, &zero, "{x|twice}", "aa")
+}
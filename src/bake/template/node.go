@@ -0,0 +1,276 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package template
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"text/scanner"
+)
+
+// The suffix of the name of the variable that holds the index of the current
+// element of a loop, so that the index of `{*Authors:Author}` is
+// `{AuthorIndex}`.
+const indexSufx = "Index"
+
+// A node is a part of a parsed template.
+type node interface {
+	// check returns an error if the node can't be expanded with `d`
+	// regardless of the values of the variables in `d`.
+	check(d *Dict) error
+
+	// exec writes the expansion of the node with `d` to `out`.
+	exec(d *Dict, out *bufio.Writer) error
+
+	// analyze records the names that the node refers to in `a`.
+	analyze(a *analyzer) error
+}
+
+func checkNodes(nodes []node, d *Dict) error {
+	var errs ErrorList
+	for _, n := range nodes {
+		if err := errs.add(n.check(d)); err != nil {
+			return err
+		}
+	}
+	return errs.Err()
+}
+
+// execNodes writes the expansion of `nodes` with `d` to `out`. Errors in the
+// template are collected, and the rest of the nodes are still expanded, but any
+// other error stops the expansion.
+func execNodes(nodes []node, d *Dict, out *bufio.Writer) error {
+	var errs ErrorList
+	for _, n := range nodes {
+		if err := errs.add(n.exec(d, out)); err != nil {
+			return err
+		}
+	}
+	return errs.Err()
+}
+
+// A textNode is text that's output as is.
+type textNode string
+
+func (n textNode) check(d *Dict) error {
+	return nil
+}
+
+func (n textNode) exec(d *Dict, out *bufio.Writer) error {
+	return d.write(out, string(n))
+}
+
+func (n textNode) analyze(a *analyzer) error {
+	return nil
+}
+
+// A varNode is replaced by the value of a variable, or by a default value if
+// the variable isn't set.
+type varNode struct {
+	name    string
+	filters []string
+	pos     scanner.Position
+
+	// If the variable may be unset, then `unset` is defaultOp or requireOp,
+	// and `arg` is its default value or the error reported if it's unset.
+	unset rune
+	arg   string
+}
+
+func (n *varNode) check(d *Dict) error {
+	for _, name := range n.filters {
+		if _, ok := d.Filter(name); !ok {
+			return posErr(n.pos, "Unknown filter '%s'", name)
+		}
+	}
+	return nil
+}
+
+func (n *varNode) exec(d *Dict, out *bufio.Writer) error {
+	val, ok := d.Get(n.name)
+	if !ok {
+		if _, isList := d.GetList(n.name); isList {
+			return posErr(n.pos, "'%s' is a list", n.name)
+		}
+
+		switch {
+		case n.unset == defaultOp:
+			val = n.arg
+		case n.unset == requireOp && n.arg != "":
+			return posErr(n.pos, "%s", n.arg)
+		default:
+			return posErr(n.pos, "Unknown variable '%s'", n.name)
+		}
+	}
+
+	for _, name := range n.filters {
+		f, ok := d.Filter(name)
+		if !ok {
+			return posErr(n.pos, "Unknown filter '%s'", name)
+		}
+		val = f(val)
+	}
+
+	return d.writeValue(out, d.escape(val, n.filters))
+}
+
+func (n *varNode) analyze(a *analyzer) error {
+	a.ref(n.name, n.unset == defaultOp)
+	a.addAll(a.filters, n.filters)
+	return nil
+}
+
+// A condNode is replaced by the body of its first branch whose condition holds.
+type condNode struct {
+	branches []branch
+}
+
+// A branch is a section of a conditional. Its condition is nil if it's the
+// "else" branch.
+type branch struct {
+	cond condExpr
+	body []node
+}
+
+func (n *condNode) check(d *Dict) error {
+	for _, b := range n.branches {
+		if err := checkNodes(b.body, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *condNode) exec(d *Dict, out *bufio.Writer) error {
+	for _, b := range n.branches {
+		if b.cond == nil || b.cond.eval(d) {
+			return execNodes(b.body, d, out)
+		}
+	}
+	return nil
+}
+
+func (n *condNode) analyze(a *analyzer) error {
+	a.depth++
+	defer func() { a.depth-- }()
+
+	for _, b := range n.branches {
+		if b.cond != nil {
+			a.cond(b.cond)
+		}
+		if err := analyzeNodes(b.body, a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A loopNode is replaced by its body once for each element of a list, with the
+// element and its index bound to variables.
+type loopNode struct {
+	list string
+	elem string
+	body []node
+	pos  scanner.Position
+}
+
+func (n *loopNode) check(d *Dict) error {
+	return checkNodes(n.body, d)
+}
+
+func (n *loopNode) exec(d *Dict, out *bufio.Writer) error {
+	vals, ok := d.GetList(n.list)
+	if !ok {
+		if d.Has(n.list) {
+			return posErr(n.pos, "'%s' is not a list", n.list)
+		}
+		return posErr(n.pos, "Unknown list '%s'", n.list)
+	}
+
+	var errs ErrorList
+	for i, val := range vals {
+		restoreElem := d.shadow(n.elem, val)
+		restoreIndex := d.shadow(n.elem+indexSufx, strconv.Itoa(i))
+		err := execNodes(n.body, d, out)
+		restoreIndex()
+		restoreElem()
+		if err = errs.add(err); err != nil {
+			return err
+		}
+	}
+
+	return errs.Err()
+}
+
+func (n *loopNode) analyze(a *analyzer) error {
+	a.ref(n.list, false)
+
+	a.bound[n.elem]++
+	a.bound[n.elem+indexSufx]++
+	err := analyzeNodes(n.body, a)
+	a.bound[n.elem+indexSufx]--
+	a.bound[n.elem]--
+
+	return err
+}
+
+// A seqNode is replaced by each of its nodes in turn.
+type seqNode []node
+
+func (n seqNode) check(d *Dict) error {
+	return checkNodes(n, d)
+}
+
+func (n seqNode) exec(d *Dict, out *bufio.Writer) error {
+	return execNodes(n, d, out)
+}
+
+func (n seqNode) analyze(a *analyzer) error {
+	return analyzeNodes(n, a)
+}
+
+// A lineNode is a section that spans whole lines. It's replaced by the section
+// along with the indentation of its first line and the end of its last line,
+// unless the section is empty, in which case it's removed entirely.
+type lineNode struct {
+	indent string
+	n      node
+	eol    string
+}
+
+func (n *lineNode) check(d *Dict) error {
+	return n.n.check(d)
+}
+
+func (n *lineNode) exec(d *Dict, out *bufio.Writer) error {
+	var errs ErrorList
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	line := d.line
+	d.line += n.indent
+	if err := errs.add(n.n.exec(d, w)); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if buf.Len() == 0 {
+		d.line = line
+	} else {
+		// The line of `d` already holds the indent and the section.
+		err := writeString(out, n.indent+buf.String()+n.eol)
+		if err != nil {
+			return err
+		}
+		d.wrote(n.eol)
+	}
+	return errs.Err()
+}
+
+func (n *lineNode) analyze(a *analyzer) error {
+	return n.n.analyze(a)
+}
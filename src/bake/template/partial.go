@@ -0,0 +1,169 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package template
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/scanner"
+)
+
+// The separator used to list the partials being expanded in error messages.
+const partialStackSep = " > "
+
+// A PartialLoader returns the path of the template file for the partial named
+// `name`.
+type PartialLoader func(name string) (string, error)
+
+// SetPartialLoader makes the partials returned by `l` available to templates
+// expanded with `d`.
+func (d *Dict) SetPartialLoader(l PartialLoader) {
+	d.partials = l
+	d.parsed = nil
+}
+
+// Is `r` legal in the name of a partial?
+func isPartialRune(r rune) bool {
+	return isVarRune(r) || strings.ContainsRune("/-_.", r)
+}
+
+// Parse the partial directive at the start of `in`, after `{>`.
+func parsePartial(in *source) (node, error) {
+	var name []rune
+	for isPartialRune(in.Peek()) {
+		name = append(name, in.Next())
+	}
+	if len(name) == 0 {
+		if isEOF(in) {
+			return nil, parseErr(in, "Expected partial name, got EOF")
+		}
+		return nil, parseErr(in, "Expected partial name, got '%c'",
+			in.Next())
+	}
+
+	n := &partialNode{string(name), in.Pos()}
+	return n, in.matchRight()
+}
+
+// A partialNode is replaced by the expansion of another template, using the
+// same dictionary.
+type partialNode struct {
+	name string
+	pos  scanner.Position
+}
+
+func (n *partialNode) check(d *Dict) error {
+	nodes, err := d.enterPartial(n)
+	if err != nil {
+		return err
+	}
+	defer d.leavePartial()
+
+	return n.wrapErr(checkNodes(nodes, d))
+}
+
+func (n *partialNode) exec(d *Dict, out *bufio.Writer) error {
+	nodes, err := d.enterPartial(n)
+	if err != nil {
+		return err
+	}
+	defer d.leavePartial()
+
+	return n.wrapErr(execNodes(nodes, d, out))
+}
+
+func (n *partialNode) analyze(a *analyzer) error {
+	if a.dict.partials == nil {
+		return nil
+	}
+
+	nodes, err := a.dict.enterPartial(n)
+	if err != nil {
+		return err
+	}
+	defer a.dict.leavePartial()
+
+	return n.wrapErr(analyzeNodes(nodes, a))
+}
+
+// wrapErr adds the position of `n` to errors from the partial it includes, so
+// that the error shows where each partial being expanded was included.
+func (n *partialNode) wrapErr(err error) error {
+	switch err := err.(type) {
+	case nil:
+		return nil
+	case ErrorList:
+		var errs ErrorList
+		for _, e := range err {
+			errs.add(n.wrapErr(e))
+		}
+		return errs.Err()
+	}
+	return posErr(n.pos, "In partial '%s': %v", n.name, err)
+}
+
+// enterPartial parses the partial included by `n` and records that it's being
+// expanded. The partial is left by calling leavePartial.
+func (d *Dict) enterPartial(n *partialNode) ([]node, error) {
+	for _, name := range d.including {
+		if name == n.name {
+			stack := append(d.including, n.name)
+			return nil, posErr(n.pos, "Partial '%s' includes itself: %s",
+				n.name, strings.Join(stack, partialStackSep))
+		}
+	}
+
+	nodes, err := d.loadPartial(n.name)
+	if errs, ok := err.(ErrorList); ok {
+		return nil, n.wrapErr(errs)
+	} else if err != nil {
+		return nil, posErr(n.pos, "Couldn't load partial '%s': %v",
+			n.name, err)
+	}
+
+	d.including = append(d.including, n.name)
+	return nodes, nil
+}
+
+func (d *Dict) leavePartial() {
+	d.including = d.including[:len(d.including)-1]
+}
+
+// loadPartial parses the partial `name` with the delimiters of `d`, whatever
+// the delimiters of the template that includes it. A single trailing newline
+// is removed from partials, so that they can be included on a line of their
+// own. Each partial is only parsed once, however often it's included.
+func (d *Dict) loadPartial(name string) ([]node, error) {
+	if d.partials == nil {
+		return nil, fmt.Errorf("no partials are available")
+	}
+	if nodes, ok := d.parsed[name]; ok {
+		return nodes, nil
+	}
+
+	fpath, err := d.partials(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		return nil, err
+	}
+	src := strings.TrimSuffix(string(data), "\n")
+
+	nodes, err := parse(newSource(fpath, src, d.Delims()))
+	if err != nil {
+		return nil, withSnippets(err, fpath, src)
+	}
+
+	if d.parsed == nil {
+		d.parsed = map[string][]node{}
+	}
+	d.parsed[name] = nodes
+	return nodes, nil
+}
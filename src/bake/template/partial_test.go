@@ -0,0 +1,148 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package template
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+// partialDict returns a dictionary of `vars` that loads the partials in
+// `partials` from a temporary directory, which is also returned.
+func partialDict(t *testing.T, vars, partials map[string]string) (*Dict,
+	string) {
+
+	dir, err := ioutil.TempDir("", "partials")
+	if err != nil {
+		t.Fatalf("Couldn't create partials directory: %v", err)
+	}
+
+	for name, src := range partials {
+		err = ioutil.WriteFile(path.Join(dir, name), []byte(src), 0666)
+		if err != nil {
+			t.Fatalf("Couldn't write partial '%s': %v", name, err)
+		}
+	}
+
+	d := NewDict(vars)
+	d.SetPartialLoader(func(name string) (string, error) {
+		if _, ok := partials[name]; !ok {
+			return "", fmt.Errorf("no partial '%s'", name)
+		}
+		return path.Join(dir, name), nil
+	})
+	return d, dir
+}
+
+func TestExpandPartial(t *testing.T) {
+	d, dir := partialDict(t,
+		map[string]string{"x": "a"},
+		map[string]string{
+			"p":     "<{x}>\n",
+			"q":     "[{>p}]",
+			"twice": "{>p}{>p}",
+			"nl":    "a\n\n",
+			"cond":  "{?x}x{:}y{?}",
+		},
+	)
+	defer os.RemoveAll(dir)
+
+	testExpand(t, d, "{>p}", "<a>")
+	testExpand(t, d, "{>p}\n", "<a>\n")
+	testExpand(t, d, "{>q}", "[<a>]")
+	testExpand(t, d, "{>twice}", "<a><a>")
+	testExpand(t, d, "{>nl}", "a\n")
+	testExpand(t, d, "{>cond}", "x")
+	testExpand(t, d, "{?z}{>q}{?}", "")
+
+	d.SetList("A", []string{"1", "2"})
+	testExpand(t, d, "{*A:x}{>p}{*}", "<1><2>")
+}
+
+func TestPartialParsedOnce(t *testing.T) {
+	d, dir := partialDict(t, nil, map[string]string{"p": "<{x}>"})
+	defer os.RemoveAll(dir)
+	d.SetList("A", []string{"1", "2", "3"})
+
+	loads := 0
+	loader := d.partials
+	d.SetPartialLoader(func(name string) (string, error) {
+		loads++
+		return loader(name)
+	})
+
+	tmpl, err := Parse("t", strings.NewReader("{*A:x}{>p}{*}"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err = tmpl.Check(d); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	testExpand(t, d, "{*A:x}{>p}{*}", "<1><2><3>")
+	testExpand(t, d, "{*A:x}{>p}{*}", "<1><2><3>")
+
+	if loads != 1 {
+		t.Errorf("Expected the partial to be loaded once, got %d", loads)
+	}
+}
+
+func TestExpandBadPartial(t *testing.T) {
+	d, dir := partialDict(t,
+		map[string]string{"x": "a"},
+		map[string]string{
+			"self":   "{>self}",
+			"a":      "{>b}",
+			"b":      "{?z}{>a}{?}",
+			"filter": "{x|nope}",
+		},
+	)
+	defer os.RemoveAll(dir)
+
+	expandFail(t, d, "{>}")
+	expandFail(t, d, "{>p q}")
+	expandFail(t, d, "{>missing}")
+	expandFail(t, d, "{>self}")
+	expandFail(t, d, "{>a}")
+	expandFail(t, d, "{?z}{>filter}{?}")
+	expandFail(t, &Dict{}, "{>p}")
+}
+
+func TestExpandPartialErrorPos(t *testing.T) {
+	d, dir := partialDict(t,
+		map[string]string{"x": "a"},
+		map[string]string{
+			"outer": "a\n{>inner}",
+			"inner": "a\nb\n{y}",
+		},
+	)
+	defer os.RemoveAll(dir)
+
+	_, err := d.ExpandStr("\n\n\n{>outer}")
+	if err == nil {
+		t.Fatalf("Expected error while expanding 'outer', got none")
+	}
+
+	// The error gives the position of each include, outermost first.
+	expected := []string{
+		"[4:",
+		"outer",
+		path.Join(dir, "outer") + "[2:",
+		"inner",
+		path.Join(dir, "inner") + "[3:",
+		"Unknown variable 'y'",
+	}
+	msg := err.Error()
+	for _, s := range expected {
+		i := strings.Index(msg, s)
+		if i < 0 {
+			t.Fatalf("Expected '%s' in error: %v", s, err)
+		}
+		msg = msg[i+len(s):]
+	}
+}
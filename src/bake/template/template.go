@@ -10,6 +10,9 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
+	"strings"
 	"text/scanner"
 )
 
@@ -18,10 +21,16 @@ const (
 	rDelim    = '}' // Denotes the end of a template directive
 	condDelim = '?' // Denotes the start/end of a conditional insert
 	condElsif = ':' // Denotes the else of a conditional insert
+	filterSep = '|' // Separates a variable from the filters applied to it
+	loopDelim = '*' // Denotes the start/end of a loop
+	loopSep   = ':' // Separates the list of a loop from its element
+	partDelim = '>' // Denotes the inclusion of a partial
+	unsetSep  = ':' // Separates a variable from how it's handled if unset
+	defaultOp = '-' // Gives the value of a variable that isn't set
+	requireOp = '?' // Gives the error reported for a variable that isn't set
+	commDelim = '#' // Denotes the start/end of a comment
 )
 
-type Dict map[string]string
-
 func (d *Dict) ExpandStr(src string) (string, error) {
 	var out bytes.Buffer
 	in := bytes.NewBufferString(src)
@@ -35,123 +44,520 @@ func (d *Dict) ExpandStr(src string) (string, error) {
 }
 
 func (d *Dict) Expand(reader io.Reader, writer io.Writer) error {
-	out := bufio.NewWriter(writer)
-	var in scanner.Scanner
-	in.Init(reader)
+	t, err := ParseWith("", reader, d.Delims())
+	if err != nil {
+		return err
+	}
+	return t.Execute(d, writer)
+}
 
-	err := copyUntilDelim(&in, out)
-	for err == nil && !isEOF(&in) {
-		if err = d.expandDirective(&in, out); err != nil {
-			break
-		}
-		err = copyUntilDelim(&in, out)
+// A Template is a parsed template, which can be expanded any number of times.
+type Template struct {
+	name   string
+	src    string
+	nodes  []node
+	delims Delims // The delimiters that the template was parsed with
+}
+
+// Parse parses the template read from `r`. `name` refers to the template in
+// errors, and is usually its path. The whole template is parsed, so errors in
+// sections that wouldn't be processed are still reported. Errors in the
+// template are returned as an ErrorList of every error that was found.
+func Parse(name string, r io.Reader) (*Template, error) {
+	return ParseWith(name, r, DefaultDelims)
+}
+
+// ParseWith parses the template read from `r` like Parse, where the directives
+// of the template begin and end with `delims` until the template changes them.
+func ParseWith(name string, r io.Reader, delims Delims) (*Template, error) {
+	if err := delims.Validate(); err != nil {
+		return nil, err
 	}
+
+	data, err := ioutil.ReadAll(r)
 	if err != nil {
+		return nil, err
+	}
+	src := string(data)
+
+	nodes, err := parse(newSource(name, src, delims))
+	if err != nil {
+		return nil, withSnippets(err, name, src)
+	}
+	return &Template{name, src, nodes, delims}, nil
+}
+
+// ParseFile parses the template in the file at `fpath`.
+func ParseFile(fpath string) (*Template, error) {
+	return ParseFileWith(fpath, DefaultDelims)
+}
+
+// ParseFileWith parses the template in the file at `fpath` with `delims`, as
+// with ParseWith.
+func ParseFileWith(fpath string, delims Delims) (*Template, error) {
+	file, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ParseWith(fpath, bufio.NewReader(file), delims)
+}
+
+// Name returns the name that `t` was parsed with.
+func (t *Template) Name() string {
+	return t.name
+}
+
+// Check returns an ErrorList of the uses of filters and partials that aren't
+// available in `d`, in any section of `t`, or nil if there are none.
+func (t *Template) Check(d *Dict) error {
+	return withSnippets(checkNodes(t.nodes, d), t.name, t.src)
+}
+
+// Execute writes the expansion of `t` with `d` to `w`. Errors in the template
+// don't stop the expansion, and are returned together in an ErrorList once the
+// rest of the template has been expanded. The output is incomplete if an error
+// is returned.
+func (t *Template) Execute(d *Dict, w io.Writer) error {
+	var errs ErrorList
+	errs.add(t.Check(d))
+
+	d.line = ""
+	out := bufio.NewWriter(w)
+	if err := errs.add(execNodes(t.nodes, d, out)); err != nil {
+		return err
+	}
+	if err := out.Flush(); err != nil {
 		return err
 	}
 
-	return out.Flush()
+	return withSnippets(errs.Err(), t.name, t.src)
 }
 
-func isEOF(in *scanner.Scanner) bool {
-	return in.Peek() == scanner.EOF
+// The kinds of directive that begin or end a section of a template, or that
+// are removed along with their line when they're on a line of their own.
+const (
+	eofTag     = iota // The end of the template
+	condTag           // `{?cond}`
+	endCondTag        // `{?}`
+	elseTag           // `{:}` or `{:cond}`
+	loopTag           // `{*list:elem}`
+	endLoopTag        // `{*}`
+	delimsTag         // `{=left right=}`
+	commentTag        // `{#text#}`
+)
+
+// A tag is a directive that begins or ends a section of a template, changes its
+// delimiters or comments on it.
+type tag struct {
+	kind int
+	cond condExpr // The condition of an "if" or "elseif", or nil
+	list string   // The list of a loop
+	elem string   // The element of a loop
+	pos  scanner.Position
+
+	delims Delims // The delimiters that a `{=left right=}` changes to
+
+	// If the tag isn't on a line of its own, then `rest` holds the blanks
+	// that were read after it while looking for the end of the line, along
+	// with the newline that ended the line, if there was one.
+	rest string
+	eol  bool // True if `rest` ends the line
+
+	// If the tag begins a section at the start of a line, but isn't on a
+	// line of its own, then `lineStart` is true and `indent` holds the
+	// indentation of the line.
+	lineStart bool
+	indent    string
 }
 
-func copyUntilDelim(in *scanner.Scanner, out *bufio.Writer) error {
-	var err error
-	for err == nil && !isEOF(in) && in.Peek() != lDelim && in.Peek() != rDelim {
-		err = copyNext(in, out)
+// opens returns true if `t` begins a section.
+func (t *tag) opens() bool {
+	return t.kind == condTag || t.kind == loopTag
+}
+
+// A parser reads the nodes of a template from a source.
+type parser struct {
+	in *source
+
+	// True if only blanks have been read since the start of the current
+	// line.
+	lineBlank bool
+
+	// Text that was read after a tag, which begins the text that follows
+	// it.
+	pending string
+
+	// The errors found so far. The parser carries on after an error so that
+	// every error in a template can be reported at once.
+	errs ErrorList
+}
+
+// Parse the template in `in` into the nodes that it's made of.
+func parse(in *source) ([]node, error) {
+	p := &parser{in: in, lineBlank: true}
+
+	var nodes []node
+	for {
+		ns, t := p.parseNodes()
+		nodes = append(nodes, ns...)
+
+		switch t.kind {
+		case endCondTag, elseTag:
+			p.errorf(t.pos, "Conditional section hasn't been opened")
+		case endLoopTag:
+			p.errorf(t.pos, "Loop hasn't been opened")
+		default:
+			if err := p.errs.Err(); err != nil {
+				return nil, err
+			}
+			return nodes, nil
+		}
+		p.pending = t.rest
 	}
-	return err
 }
 
-func copyNext(in *scanner.Scanner, out *bufio.Writer) error {
-	c := in.Next()
-	if out != nil {
-		if n, err := out.WriteRune(c); err == nil && n < 1 {
-			return fmt.Errorf("Couldn't write: %c", c)
-		} else if err != nil {
-			return err
+// fail records `err`, which the scanning functions only return for errors in
+// the template.
+func (p *parser) fail(err error) {
+	p.errs.add(err)
+}
+
+func (p *parser) errorf(pos scanner.Position, msg string,
+	params ...interface{}) {
+
+	p.fail(posErr(pos, msg, params...))
+}
+
+// skip recovers from an error in a directive by skipping the rest of it, up to
+// and including its closing delimiter, but not past the end of its line.
+func (p *parser) skip() {
+	for !isEOF(p.in) && p.in.Peek() != '\n' {
+		if p.in.atRight() {
+			p.in.skip(p.in.delims.Right)
+			return
 		}
+		p.in.Next()
 	}
-	return nil
 }
 
-func (d *Dict) expandDirective(in *scanner.Scanner, out *bufio.Writer) error {
-	var err error
+func isEOF(in *source) bool {
+	return in.Peek() == scanner.EOF
+}
+
+// Is `r` whitespace that doesn't end a line?
+func isBlank(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\r'
+}
 
-	c := in.Next()
-	switch c {
-	case lDelim:
-		switch in.Peek() {
-		case lDelim:
-			return copyNext(in, out)
-		case condDelim:
-			in.Next()
-			err = d.expandCond(in, out)
+// Parse nodes from `p` until a directive that ends a section, or EOF, and
+// return them along with that directive.
+//
+// A tag that begins or ends a section and is on a line of its own, aside from
+// blanks, is removed along with the whole of its line.
+func (p *parser) parseNodes() ([]node, *tag) {
+	var nodes []node
+	var text bytes.Buffer
+	flush := func() {
+		if text.Len() > 0 {
+			nodes = append(nodes, textNode(text.String()))
+			text.Reset()
+		}
+	}
+
+	for {
+		if len(p.pending) > 0 {
+			text.WriteString(p.pending)
+			p.lineBlank = strings.HasSuffix(p.pending, "\n")
+			p.pending = ""
+		}
+
+		delims := p.in.delims
+		switch {
+		case isEOF(p.in):
+			flush()
+			return nodes, &tag{kind: eofTag, pos: p.in.Pos()}
+		case p.in.atRight():
+			p.in.skip(delims.Right)
+			if err := p.in.matchRight(); err != nil {
+				p.fail(err)
+			} else {
+				text.WriteString(delims.Right)
+			}
+			p.lineBlank = false
+			continue
+		case p.in.at(delims.Left):
+			p.in.skip(delims.Left)
+			if p.in.at(delims.Left) {
+				p.in.skip(delims.Left)
+				text.WriteString(delims.Left)
+				p.lineBlank = false
+				continue
+			}
 		default:
-			if err = d.expandVar(in, out); err == nil {
-				err = match(in, rDelim)
+			c := p.in.Next()
+			text.WriteRune(c)
+			p.lineBlank = c == '\n' || p.lineBlank && isBlank(c)
+			continue
+		}
+
+		var n node
+		var err error
+		switch p.in.Peek() {
+		case rawDelim:
+			p.in.Next()
+			raw, err := readRaw(p.in)
+			if err != nil {
+				p.fail(err)
+				continue
+			}
+			text.WriteString(raw)
+			p.lineBlank = strings.HasSuffix(raw, "\n")
+			continue
+		case condDelim, condElsif, loopDelim, delimsDelim, commDelim:
+			t, err := p.readTag()
+			if err != nil {
+				p.fail(err)
+				p.skip()
+			}
+
+			// A section that begins a line takes the indentation of
+			// the line, so that they can be removed along with the
+			// newline that ends the section if it's empty.
+			lineStart := p.lineBlank
+			if p.endLine(t) {
+				trimIndent(&text)
+			} else if lineStart && t.opens() {
+				t.lineStart = true
+				t.indent = trimIndent(&text)
+			}
+			flush()
+
+			switch t.kind {
+			case condTag:
+				nodes = append(nodes, p.parseCondSection(t))
+			case loopTag:
+				nodes = append(nodes, p.parseLoop(t))
+			case delimsTag:
+				if err == nil {
+					p.in.delims = t.delims
+				}
+			case commentTag:
+			default:
+				return nodes, t
 			}
+			continue
+		case partDelim:
+			flush()
+			p.in.Next()
+			n, err = parsePartial(p.in)
+		default:
+			flush()
+			n, err = parseVar(p.in)
 		}
-	case rDelim:
-		if err = writeString(out, "}"); err == nil {
-			err = match(in, rDelim)
+		if err != nil {
+			p.fail(err)
+			p.skip()
+		} else {
+			nodes = append(nodes, n)
 		}
-	case scanner.EOF:
-		err = parseErr(in, "Expected '%c' or '%c', got EOF", lDelim, rDelim)
-	default:
-		err = parseErr(in, "Expected '%c' or '%c', got '%c'", lDelim, rDelim, c)
+		p.lineBlank = false
 	}
+}
 
-	return err
+// Read the tag at the start of `p`, after its opening delimiter. The tag is
+// returned even if there's an error, so that parsing can carry on.
+func (p *parser) readTag() (*tag, error) {
+	in := p.in
+	t := &tag{pos: in.Pos()}
+
+	var err error
+	switch in.Next() {
+	case condDelim:
+		t.kind = endCondTag
+		if !in.atRight() {
+			t.kind = condTag
+			t.cond, err = readCond(in)
+		}
+	case condElsif:
+		t.kind = elseTag
+		if !in.atRight() {
+			t.cond, err = readCond(in)
+		}
+	case loopDelim:
+		t.kind = endLoopTag
+		if !in.atRight() {
+			t.kind = loopTag
+			t.list, t.elem, err = readLoop(in)
+		}
+	case delimsDelim:
+		t.kind = delimsTag
+		t.delims, err = readDelims(in)
+		return t, err
+	case commDelim:
+		t.kind = commentTag
+		return t, readComment(in)
+	}
+	if err != nil {
+		// A branch with a broken condition mustn't be taken to be an
+		// "else".
+		if t.kind == condTag || t.kind == elseTag {
+			t.cond = varCond("")
+		}
+		return t, err
+	}
+
+	return t, in.matchRight()
+}
+
+// Read the text of a comment from `in`, after its opening `{#`, up to and
+// including the `#}` that closes it. Comments may span lines and contain any
+// text other than `#}`.
+func readComment(in *source) error {
+	pos := in.Pos()
+	end := string(commDelim) + in.delims.Right
+
+	for !in.at(end) {
+		if isEOF(in) {
+			return posErr(pos, "Comment hasn't been closed")
+		}
+		in.Next()
+	}
+	in.skip(end)
+
+	return nil
+}
+
+// Read the blanks that follow `t`, and the newline after them, if there is
+// one. If `t` is on a line of its own then it consumes the blanks and the
+// newline and true is returned. Otherwise what was read is stored in `t.rest`,
+// and false is returned.
+func (p *parser) endLine(t *tag) bool {
+	var rest bytes.Buffer
+	for isBlank(p.in.Peek()) {
+		rest.WriteRune(p.in.Next())
+	}
+
+	t.eol = isEOF(p.in) || p.in.Peek() == '\n'
+	if t.eol && !isEOF(p.in) {
+		rest.WriteRune(p.in.Next())
+	}
+
+	if p.lineBlank && t.eol {
+		return true
+	}
+
+	// The text after a tag that ends a section is handled once the section
+	// is known to end its line.
+	t.rest = rest.String()
+	if t.kind != endCondTag && t.kind != endLoopTag {
+		p.pending = t.rest
+	}
+	p.lineBlank = false
+	return false
+}
+
+// Remove the blanks after the last newline in `text` and return them.
+func trimIndent(text *bytes.Buffer) string {
+	s := text.String()
+	i := len(s)
+	for i > 0 && isBlank(rune(s[i-1])) {
+		i--
+	}
+	text.Truncate(i)
+	return s[i:]
 }
 
 // Consume the next rune in `n` and return an error if it's not `r`.
-func match(in *scanner.Scanner, r rune) error {
+func match(in *source, r rune) error {
 	var err error
 	if c := in.Next(); c == scanner.EOF {
 		err = parseErr(in, "Expected '%c', got EOF", r)
 	} else if c != r {
-		err = parseErr(in, "Expected '%c', got '%c'", r, c)
+		err = parseErr(in, "Expected '%c', got %q", r, c)
 	}
 	return err
 }
 
-func parseErr(s *scanner.Scanner, msg string, params ...interface{}) error {
-	p := s.Pos()
-	text := fmt.Sprintf(msg, params...)
-	return fmt.Errorf("%s[%d:%d] %s", p.Filename, p.Line, p.Column-1, text)
+func parseErr(s *source, msg string, params ...interface{}) error {
+	return posErr(s.Pos(), msg, params...)
 }
 
-func (d *Dict) expandVar(in *scanner.Scanner, out *bufio.Writer) error {
+// Parse the variable directive at the start of `in`, after its opening
+// delimiter.
+func parseVar(in *source) (node, error) {
+	pos := in.Pos()
 	name, err := readVar(in)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	n := &varNode{name: name, pos: pos}
+
+	for in.Peek() == filterSep {
+		in.Next()
+		fname, err := readVar(in)
+		if err != nil {
+			return nil, err
+		}
+		n.filters = append(n.filters, fname)
 	}
 
-	val, ok := (*d)[name]
-	if !ok {
-		return parseErr(in, "Unknown variable '%s'", name)
+	if in.Peek() == unsetSep {
+		in.Next()
+		if r := in.Peek(); r != defaultOp && r != requireOp {
+			return nil, parseErr(in, "Expected '%c' or '%c' after '%c'",
+				defaultOp, requireOp, unsetSep)
+		}
+		n.unset = in.Next()
+
+		arg, err := readUnsetArg(in)
+		if err != nil {
+			return nil, err
+		}
+		n.arg = arg
 	}
 
-	return writeString(out, val)
+	return n, in.matchRight()
 }
 
-func readVar(in *scanner.Scanner) (s string, err error) {
+// Read the default value or error message of a variable directive from `in`,
+// which runs until the end of the directive.
+func readUnsetArg(in *source) (string, error) {
+	var buf bytes.Buffer
+
+	for !in.atRight() {
+		switch {
+		case isEOF(in):
+			return "", parseErr(in, "Expected '%s', got EOF",
+				in.delims.Right)
+		case in.at(in.delims.Left), in.Peek() == '\n':
+			pos := in.Pos()
+			return "", posErr(pos, "Unexpected character %q", in.Next())
+		}
+		buf.WriteRune(in.Next())
+	}
+
+	return buf.String(), nil
+}
+
+// Read a variable or filter name from `in`.
+func readVar(in *source) (string, error) {
 	var buf bytes.Buffer
 
 	for isVarRune(in.Peek()) {
 		buf.WriteRune(in.Next())
 	}
 
-	s = buf.String()
-	if in.Peek() != rDelim {
-		err = parseErr(in, "Unexpected character '%c'", in.Next())
-	} else if len(s) == 0 {
-		err = parseErr(in, "Empty variable")
+	if buf.Len() > 0 {
+		return buf.String(), nil
+	} else if isEOF(in) {
+		return "", parseErr(in, "Expected name, got EOF")
+	} else if in.atRight() {
+		return "", parseErr(in, "Empty variable")
 	}
-	return
+	pos := in.Pos()
+	return "", posErr(pos, "Unexpected character '%c'", in.Next())
 }
 
 // Is `r` a legal in a variable name?
@@ -162,10 +568,6 @@ func isVarRune(r rune) bool {
 }
 
 func writeString(out *bufio.Writer, s string) error {
-	if out == nil {
-		return nil
-	}
-
 	n := 0
 	var err error
 	if n, err = out.WriteString(s); err == nil && n != len(s) {
@@ -174,70 +576,78 @@ func writeString(out *bufio.Writer, s string) error {
 	return err
 }
 
-func (d *Dict) expandCond(in *scanner.Scanner, writer *bufio.Writer) error {
-	expand, err := d.evalBool(in)
-	if err != nil {
-		return err
+// Parse the conditional section that begins with `open`.
+func (p *parser) parseCondSection(open *tag) node {
+	n := &condNode{}
+	c := open.cond
+	hasElse := false
+	for {
+		body, t := p.parseNodes()
+		n.branches = append(n.branches, branch{c, body})
+
+		switch t.kind {
+		case endCondTag:
+			return p.endSection(n, open, t)
+		case elseTag:
+			if hasElse {
+				p.errorf(t.pos, "Conditional section already has an else")
+			}
+			c = t.cond
+			hasElse = hasElse || c == nil
+		case endLoopTag:
+			p.errorf(t.pos,
+				"Expected end of conditional section, got end of loop")
+			return p.endSection(n, open, t)
+		default:
+			p.errorf(t.pos, "Conditional section hasn't been closed")
+			return n
+		}
 	}
+}
 
-	var out *bufio.Writer
-	expanded := false
-	for err == nil && !isEOF(in) {
-		if !expanded && expand {
-			out = writer
-			expanded = true
-		}
+// Read the list and element of a loop from `in`.
+func readLoop(in *source) (list, elem string, err error) {
+	if list, err = readVar(in); err != nil {
+		return "", "", err
+	}
+	if err = match(in, loopSep); err != nil {
+		return "", "", err
+	}
+	elem, err = readVar(in)
+	return list, elem, err
+}
 
-		if err = copyUntilDelim(in, out); err != nil {
-			break
-		}
+// Parse the loop that begins with `open`.
+func (p *parser) parseLoop(open *tag) node {
+	n := &loopNode{list: open.list, elem: open.elem, pos: open.pos}
 
-		if in.Peek() == lDelim {
-			in.Next()
+	body, t := p.parseNodes()
+	n.body = body
 
-			switch in.Peek() {
-			case lDelim:
-				err = copyNext(in, out)
-			case condDelim:
-				in.Next()
-				if in.Peek() == rDelim {
-					in.Next()
-					return nil
-				} else {
-					err = d.expandCond(in, out)
-				}
-			case condElsif:
-				in.Next()
-				out = nil
-				if in.Peek() == rDelim {
-					in.Next()
-					expand = true
-				} else {
-					expand, err = d.evalBool(in)
-				}
-			default:
-				err = d.expandVar(in, out)
-				if out == nil || err == nil {
-					err = match(in, rDelim)
-				}
-			}
-		} else {
-			err = d.expandDirective(in, out)
-		}
+	switch t.kind {
+	case endLoopTag:
+		return p.endSection(n, open, t)
+	case endCondTag, elseTag:
+		p.errorf(t.pos,
+			"Expected end of loop, got part of a conditional section")
+		return p.endSection(n, open, t)
 	}
-
-	return err
+	p.errorf(t.pos, "Loop hasn't been closed")
+	return n
 }
 
-func (d *Dict) evalBool(in *scanner.Scanner) (bool, error) {
-	name, err := readVar(in)
-	if err != nil {
-		return false, err
+// endSection returns the node for the section `n`, which begins with `open` and
+// ends with `close`. A section that begins a line and ends a line is removed
+// along with its indentation and its newline if it's empty.
+func (p *parser) endSection(n node, open, close *tag) node {
+	if open.lineStart && close.eol {
+		p.lineBlank = true
+		return &lineNode{open.indent, n, close.rest}
 	}
-	return d.hasVar(name), match(in, rDelim)
-}
 
-func (d *Dict) hasVar(name string) bool {
-	_, ok := (*d)[name]
-	return ok
+	p.pending = close.rest
+	if len(open.indent) > 0 {
+		return seqNode{textNode(open.indent), n}
+	}
+	return n
 }
@@ -5,6 +5,8 @@
 package template
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 )
 
@@ -14,7 +16,7 @@ func TestExpandEmpty(t *testing.T) {
 
 func TestExpandVar(t *testing.T) {
 	before, after := "a", "b"
-	d := &Dict{before: after}
+	d := NewDict(map[string]string{before: after})
 
 	testExpand(t, d, before, before)
 	testExpand(t, d, "{"+before+"}", after)
@@ -34,7 +36,7 @@ func testExpand(t *testing.T, d *Dict, before, after string) {
 	}
 }
 
-func TestexpandEmptyVar(t *testing.T) {
+func TestExpandEmptyVar(t *testing.T) {
 	expandFail(t, &Dict{}, "{}")
 }
 
@@ -49,6 +51,54 @@ func TestExpandUnknownName(t *testing.T) {
 	expandFail(t, &Dict{}, "{x}")
 }
 
+func TestExpandDefault(t *testing.T) {
+	d := NewDict(map[string]string{"x": "a", "e": ""})
+	d.SetList("l", []string{"b"})
+
+	testExpand(t, d, "{x:-none}", "a")
+	testExpand(t, d, "{y:-none}", "none")
+	testExpand(t, d, "{e:-none}", "")
+	testExpand(t, d, "{y:-}", "")
+	testExpand(t, d, "{y:-no value, sorry!}", "no value, sorry!")
+	testExpand(t, d, "{y|upper:-none}", "NONE")
+	testExpand(t, d, "{x:?x isn't set}", "a")
+
+	for _, src := range []string{
+		"{y:}",
+		"{y:+a}",
+		"{y:-a",
+		"{y:-a{b}}",
+		"{y:-a\nb}",
+		"{l:-a}",
+	} {
+		expandFail(t, d, src)
+	}
+}
+
+func TestExpandRequired(t *testing.T) {
+	tmpl, err := Parse("README", strings.NewReader("a\n{?x}b{?}{y:?Set y!}"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	err = tmpl.Execute(&Dict{}, &bytes.Buffer{})
+	if err == nil {
+		t.Fatalf("Expected error for unset variable, got none")
+	} else if err.Error() != "README[2:9] Set y!" {
+		t.Errorf("Expected 'README[2:9] Set y!', got '%v'", err)
+	}
+
+	err = tmpl.Execute(NewDict(map[string]string{"y": ""}), &bytes.Buffer{})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	_, err = (&Dict{}).ExpandStr("{y:?}")
+	if err == nil || !strings.Contains(err.Error(), "Unknown variable") {
+		t.Errorf("Expected unknown variable error, got '%v'", err)
+	}
+}
+
 func TestExpandDoubleLBrace(t *testing.T) {
 	padding := " "
 	d := &Dict{}
@@ -80,7 +130,7 @@ func TestExpandDoubleRBrace(t *testing.T) {
 }
 
 func TestExpandSimpleCond(t *testing.T) {
-	d := &Dict{"x": "a", "y": ""}
+	d := NewDict(map[string]string{"x": "a", "y": ""})
 
 	testExpand(t, d, "<{?x}{x}{?}>", "<a>")
 	testExpand(t, d, "<{?x}c{x}{?}>", "<ca>")
@@ -102,7 +152,7 @@ func TestExpandSimpleCond(t *testing.T) {
 }
 
 func TestExpandCondElse(t *testing.T) {
-	d := &Dict{"x": "a", "y": ""}
+	d := NewDict(map[string]string{"x": "a", "y": ""})
 
 	testExpand(t, d, "<{?x}{x}{:}b{?}>", "<a>")
 	testExpand(t, d, "<{?z}{z}{:}b{?}>", "<b>")
@@ -117,7 +167,7 @@ func TestExpandCondElse(t *testing.T) {
 }
 
 func TestExpandCondElsif(t *testing.T) {
-	d := &Dict{"x": "a"}
+	d := NewDict(map[string]string{"x": "a"})
 
 	testExpand(t, d, "<{?z}1{:z}2{:z}3{?}>", "<>")
 
@@ -131,3 +181,337 @@ func TestExpandCondElsif(t *testing.T) {
 	testExpand(t, d, "<{?z}1{:z}2{:x}{x}{:}4{?}>", "<a>")
 	testExpand(t, d, "<{?z}1{:z}2{:z}3{:}{x}{?}>", "<a>")
 }
+
+func TestExpandCondOps(t *testing.T) {
+	d := NewDict(map[string]string{"x": "", "y": ""})
+
+	testExpand(t, d, "<{?!x}1{:}2{?}>", "<2>")
+	testExpand(t, d, "<{?!z}1{:}2{?}>", "<1>")
+	testExpand(t, d, "<{?!!x}1{:}2{?}>", "<1>")
+
+	testExpand(t, d, "<{?x&y}1{:}2{?}>", "<1>")
+	testExpand(t, d, "<{?x&z}1{:}2{?}>", "<2>")
+	testExpand(t, d, "<{?x|z}1{:}2{?}>", "<1>")
+	testExpand(t, d, "<{?z|z}1{:}2{?}>", "<2>")
+
+	// "or" binds more tightly than "and".
+	testExpand(t, d, "<{?z|x&z}1{:}2{?}>", "<2>")
+	testExpand(t, d, "<{?z|(x&y)}1{:}2{?}>", "<1>")
+	testExpand(t, d, "<{?!(x&z)}1{:}2{?}>", "<1>")
+	testExpand(t, d, "<{?z}1{:!z&x}2{?}>", "<2>")
+}
+
+func TestExpandCondCmp(t *testing.T) {
+	d := NewDict(map[string]string{
+		"License":   "MIT",
+		"GoVersion": "1.21.3",
+		"x":         "",
+	})
+	d.SetList("L", []string{"MIT"})
+
+	for src, expected := range map[string]bool{
+		"License=MIT":           true,
+		"License=GPL":           false,
+		"License!=GPL":          true,
+		"License!=MIT":          false,
+		"License=GPL,MIT":       true,
+		"License=GPL,BSD-3":     false,
+		"Missing=MIT":           false,
+		"Missing!=MIT":          true,
+		"L=MIT":                 false,
+		"GoVersion>=1.21":       true,
+		"GoVersion>=1.21.4":     false,
+		"GoVersion>1.21.3":      false,
+		"GoVersion<=1.21.3":     true,
+		"GoVersion<2":           true,
+		"GoVersion<1.3":         false,
+		"Missing<2":             false,
+		"x&License=MIT":         true,
+		"!License=MIT|x":        true,
+		"(License=GPL)|!x":      false,
+		"GoVersion>1&License=A": false,
+	} {
+		src = "{?" + src + "}1{:}0{?}"
+		if expected {
+			testExpand(t, d, src, "1")
+		} else {
+			testExpand(t, d, src, "0")
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, test := range []struct {
+		a, b string
+		cmp  int
+	}{
+		{"1.2", "1.2.0", 0},
+		{"v1.10", "1.9", 1},
+		{"1.9", "1.10", -1},
+		{"1.2rc1", "1.2.1", 1},
+		{"1.b", "1.a", 1},
+	} {
+		cmp := compareVersions(test.a, test.b)
+		if cmp < 0 && test.cmp >= 0 || cmp > 0 && test.cmp <= 0 ||
+			cmp == 0 && test.cmp != 0 {
+
+			t.Errorf("Expected comparison of '%s' and '%s' to be %d, "+
+				"got %d", test.a, test.b, test.cmp, cmp)
+		}
+	}
+}
+
+func TestExpandBadCond(t *testing.T) {
+	d := NewDict(map[string]string{"x": ""})
+
+	for _, src := range []string{
+		"{?}{?}",
+		"{?!}{?}",
+		"{?x&}{?}",
+		"{?x|}{?}",
+		"{?(x}{?}",
+		"{?x)}{?}",
+		"{?x y}{?}",
+		"{?x=a}{?}",
+		"{?X=}{?}",
+		"{?X=a,}{?}",
+		"{?X!a}{?}",
+		"{?X=a b}{?}",
+		"{?X<a}{?}",
+		"{?X>=1,2}{?}",
+		"{?X>=1.}{?}",
+		"{?X<=+1}{?}",
+	} {
+		expandFail(t, d, src)
+	}
+}
+
+func TestParseCond(t *testing.T) {
+	c, err := ParseCond("a&!(b|c)")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !c.Eval(NewDict(map[string]string{"a": ""})) {
+		t.Errorf("Expected '%s' to hold", c)
+	}
+	if c.Eval(NewDict(map[string]string{"a": "", "c": ""})) {
+		t.Errorf("Expected '%s' not to hold", c)
+	}
+
+	if _, err = ParseCond("a&"); err == nil {
+		t.Errorf("Expected error while parsing 'a&', got none")
+	}
+}
+
+func TestExpandLoop(t *testing.T) {
+	d := NewDict(map[string]string{"x": "q"})
+	d.SetList("A", []string{"a", "b", "c"})
+	d.SetList("B", []string{"1", "2"})
+	d.SetList("E", nil)
+
+	testExpand(t, d, "<{*A:x}{x}{*}>", "<abc>")
+	testExpand(t, d, "<{*A:x}{xIndex}:{x} {*}>", "<0:a 1:b 2:c >")
+	testExpand(t, d, "<{*E:x}{x}{*}>", "<>")
+	testExpand(t, d, "<{*A:y}{y}{*}{x}>", "<abcq>")
+	testExpand(t, d, "<{*A:x}{x}{*}{x}>", "<abcq>")
+	testExpand(t, d, "<{*A:x}{*B:y}{x}{y} {*}{*}>", "<a1 a2 b1 b2 c1 c2 >")
+	testExpand(t, d, "<{*A:A}{A}{*}{*A:y}{y}{*}>", "<abcabc>")
+
+	testExpand(t, d, "<{?A}1{:}2{?}>", "<1>")
+	testExpand(t, d, "<{?E}1{:}2{?}>", "<2>")
+	testExpand(t, d, "<{*A:x}{?z}1{:}{x}{?}{*}>", "<abc>")
+	testExpand(t, d, "<{?A}{*B:y}{y}{*}{?}>", "<12>")
+	testExpand(t, d, "<{?z}{*Z:y}{y}{*}{?}>", "<>")
+}
+
+func TestExpandBadLoop(t *testing.T) {
+	d := NewDict(map[string]string{"x": ""})
+	d.SetList("A", []string{"a"})
+
+	for _, src := range []string{
+		"{*A:y}",
+		"{*A:y}{?}",
+		"{?x}{*A:y}{?}{*}",
+		"{*}",
+		"{*A}{*}",
+		"{*A:}{*}",
+		"{*:y}{*}",
+		"{*x:y}{*}",
+		"{*Z:y}{*}",
+		"{A}",
+	} {
+		expandFail(t, d, src)
+	}
+}
+
+func TestExpandErrorPos(t *testing.T) {
+	d := NewDict(map[string]string{"x": ""})
+
+	for src, pos := range map[string]string{
+		"a\nb{y}":            "[2:",
+		"a\n\n{*y:z}{*}":     "[3:",
+		"{?x}\na\n{:}\n{:}":  "[4:",
+		"a\n{?x}\n{*}\n{?}":  "[3:",
+		"a\nb\nc\n{?x}\nb}c": "[5:",
+	} {
+		_, err := d.ExpandStr(src)
+		if err == nil {
+			t.Errorf("Expected error while parsing '%s', got none", src)
+		} else if !strings.Contains(err.Error(), pos) {
+			t.Errorf("Expected error at %s...], got: %v", pos, err)
+		}
+	}
+}
+
+func TestExpandErrorColumn(t *testing.T) {
+	d := NewDict(map[string]string{"x": ""})
+
+	for src, pos := range map[string]string{
+		"{!}":        "[1:1]",
+		"ab\n{x:-{}": "[2:4]",
+		"{?x&!}{?}":  "[1:5]",
+		"{?X=}{?}":   "[1:4]",
+		"{=<% %> =}": "[1:7]",
+	} {
+		_, err := d.ExpandStr(src)
+		if err == nil {
+			t.Errorf("Expected error while parsing '%s', got none", src)
+		} else if !strings.Contains(err.Error(), pos) {
+			t.Errorf("Expected error at %s, got: %v", pos, err)
+		}
+	}
+}
+
+func TestExpandStandaloneLines(t *testing.T) {
+	src := "" +
+		"These sentences can be tricky.\n" +
+		"{?worry}\n" +
+		"Don't worry though.\n" +
+		"{:}\n" +
+		"Good thing you didn't worry.\n" +
+		"{?}\n" +
+		"It'll be fine.\n"
+
+	testExpand(t, &Dict{}, src, ""+
+		"These sentences can be tricky.\n"+
+		"Good thing you didn't worry.\n"+
+		"It'll be fine.\n")
+	testExpand(t, NewDict(map[string]string{"worry": ""}), src, ""+
+		"These sentences can be tricky.\n"+
+		"Don't worry though.\n"+
+		"It'll be fine.\n")
+
+	d := NewDict(map[string]string{"x": ""})
+	testExpand(t, d, "a\n  {?x}  \n  b\n\t{?}\nc\n", "a\n  b\nc\n")
+	testExpand(t, d, "a\n{?z}\nb\n{:x}\nc\n{?}", "a\nc\n")
+	testExpand(t, d, "a\r\n{?z}\r\nb\r\n{?}\r\nc", "a\r\nc")
+	testExpand(t, d, "{?x}\n{?z}\nb\n{?}\n{?}\nc", "c")
+
+	d.SetList("A", []string{"1", "2"})
+	testExpand(t, d, "a\n{*A:y}\n+ {y}\n{*}\nc\n", "a\n+ 1\n+ 2\nc\n")
+	testExpand(t, d, "a\n{*A:y}\n{?x}\n+ {y}\n{?}\n{*}\nc\n",
+		"a\n+ 1\n+ 2\nc\n")
+
+	// Tags that share their line with other text are left alone.
+	testExpand(t, d, "a {?x}\nb\n{?} c\n", "a \nb\n c\n")
+	testExpand(t, d, "a\n{?x}b\n{?} c\n", "a\nb\n c\n")
+	testExpand(t, d, "a\n{{?x}}\n", "a\n{?x}\n")
+}
+
+func TestExpandWholeLineSections(t *testing.T) {
+	d := NewDict(map[string]string{"x": "", "e": ""})
+	d.SetList("A", []string{"1", "2"})
+	d.SetList("E", nil)
+
+	testExpand(t, d, "a\n{?x}b{?}\nc", "a\nb\nc")
+	testExpand(t, d, "a\n{?z}b{?}\nc", "a\nc")
+	testExpand(t, d, "a\n  {?x}b{?}\nc", "a\n  b\nc")
+	testExpand(t, d, "a\n  {?z}b{?}  \nc", "a\nc")
+	testExpand(t, d, "a\n{?z}b{:}{e}{?}\nc", "a\nc")
+	testExpand(t, d, "a\n{?x}b\nc{?}\nd", "a\nb\nc\nd")
+	testExpand(t, d, "a\n{?z}b\nc{?}\nd", "a\nd")
+	testExpand(t, d, "a\n{?z}b{?}", "a\n")
+
+	testExpand(t, d, "a\n{*A:y}{y} {*}\nc", "a\n1 2 \nc")
+	testExpand(t, d, "a\n{*E:y}{y} {*}\nc", "a\nc")
+
+	// Sections that don't span whole lines keep their surroundings.
+	testExpand(t, d, "a\n  {?z}b{?} c\n", "a\n   c\n")
+	testExpand(t, d, "a\n- {?z}b{?}\nc", "a\n- \nc")
+}
+
+func TestExpandComment(t *testing.T) {
+	d := NewDict(map[string]string{"x": "a"})
+
+	testExpand(t, d, "{x}{# note #}{x}", "aa")
+	testExpand(t, d, "{#{x} {?y} }} {#}{x}", "a")
+	testExpand(t, d, "a\n{# note #}\nb\n", "a\nb\n")
+	testExpand(t, d, "a\n  {# a longer\nnote #}  \nb\n", "a\nb\n")
+	testExpand(t, d, "a\n{# note #} b\n", "a\n b\n")
+	testExpand(t, d, "a {# note #}\nb\n", "a \nb\n")
+	testExpand(t, d, "{?x}\n{# note #}\n{x}\n{?}\n", "a\n")
+	testExpand(t, d, "{{# note #}}", "{# note #}")
+	testExpand(t, d, "{=<% %>=}<%# {x} #%><%x%>", "a")
+
+	expandFail(t, d, "{# note")
+	expandFail(t, d, "{# note #")
+	expandFail(t, d, "{# note }")
+
+	var out bytes.Buffer
+	src := "{# Expand strips comments too. #}\n{x}\n"
+	if err := d.Expand(strings.NewReader(src), &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	} else if out.String() != "a\n" {
+		t.Errorf("Expected 'a\\n', got '%s'", out.String())
+	}
+}
+
+func TestParseExecute(t *testing.T) {
+	tmpl, err := Parse("t", strings.NewReader("{?x}{x}{:}none{?}"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, test := range []struct {
+		d        *Dict
+		expected string
+	}{
+		{NewDict(map[string]string{"x": "a"}), "a"},
+		{NewDict(map[string]string{"x": "b"}), "b"},
+		{&Dict{}, "none"},
+	} {
+		var out bytes.Buffer
+		if err = tmpl.Execute(test.d, &out); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		} else if out.String() != test.expected {
+			t.Errorf("Expected '%s', got '%s'", test.expected, &out)
+		}
+	}
+}
+
+func TestParseUntakenBranch(t *testing.T) {
+	for _, src := range []string{
+		"{?x}{y|}{?}",
+		"{?x}{:}{*y}{*}{?}",
+		"{?x}{?}{:x&}{?}",
+	} {
+		tmpl, err := Parse("t", strings.NewReader(src))
+		if err == nil {
+			t.Errorf("Expected error while parsing '%s', got none", src)
+		} else if !strings.HasPrefix(err.Error(), "t[") {
+			t.Errorf("Expected error to start with 't[', got: %v", err)
+		}
+		if tmpl != nil {
+			t.Errorf("Expected no template for '%s'", src)
+		}
+	}
+
+	tmpl, err := Parse("t", strings.NewReader("{?x}{y|nope}{?}"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err = tmpl.Check(&Dict{}); err == nil {
+		t.Errorf("Expected error checking unknown filter, got none")
+	}
+}
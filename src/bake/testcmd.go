@@ -0,0 +1,66 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bake/env"
+	"bake/proj"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+func testUsage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n"+
+		"  %s test [lang...]\n", os.Args[0])
+}
+
+// runTestCmd runs the `bake test` command with the arguments following `test`
+// and exits. It runs the tests of the recipes for the given languages, or of
+// every supported language if none are given, and prints the status of each.
+// It fails if any of the tests fail.
+func runTestCmd(args []string) {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			testUsage()
+			os.Exit(2)
+		}
+	}
+
+	langs := args
+	if len(langs) == 0 {
+		var err error
+		if langs, err = env.SupportedLangs(); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
+		sort.Strings(langs)
+	}
+	for _, lang := range langs {
+		validateLang(lang)
+	}
+
+	exitStatus := 0
+	for _, lang := range langs {
+		t := time.Now()
+
+		status := "ok"
+		if passed, err := proj.Test(lang); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			status = "FAIL"
+		} else if !passed {
+			status = "FAIL"
+		}
+		if status != "ok" {
+			exitStatus = 1
+		}
+
+		fmt.Printf("%s\t%s-recipe\t%.3fs\n", status, lang,
+			time.Since(t).Seconds())
+	}
+	os.Exit(exitStatus)
+}
@@ -0,0 +1,158 @@
+// Copyright 2014 Sean Kelleher. All rights reserved.
+// Use of this source code is governed by a GPL
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bake/proj"
+	"bake/recipe"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+func varsUsage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n"+
+		"  %s vars lang [-t types] [--format f]\n", os.Args[0])
+}
+
+// runVarsCmd runs the `bake vars` command with the arguments following `vars`
+// and exits. It prints each variable referred to by the templates for a
+// project of the given types, with whether it's required, optional or used in
+// conditions, and marks the variables that bake doesn't supply, which don't
+// include those that the types declare. It fails if any required variable isn't
+// supplied.
+func runVarsCmd(args []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		varsUsage()
+		os.Exit(2)
+	}
+	lang := args[0]
+
+	var ts stringSlice
+	flags := flag.NewFlagSet("vars", flag.ExitOnError)
+	flags.Usage = varsUsage
+	flags.Var(&ts, "t", "The project's types")
+	alias(flags, "t", "type")
+	addFormatFlag(flags)
+	flags.Parse(args[1:])
+	validateFormat()
+
+	if flags.NArg() != 0 {
+		varsUsage()
+		os.Exit(2)
+	}
+
+	validateLang(lang)
+
+	r, err := recipe.For(lang)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+
+	a, err := proj.Vars(r, ts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+
+	supplied, err := suppliedVars(r)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+
+	decls, err := proj.DeclaredVars(r, ts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	for _, v := range decls {
+		supplied[v.Name] = true
+	}
+
+	kinds := map[string][]string{}
+	addKind := func(kind string, names []string) {
+		for _, name := range names {
+			kinds[name] = append(kinds[name], kind)
+		}
+	}
+	addKind("required", a.Required)
+	addKind("optional", a.Optional)
+	addKind("condition", a.Conds)
+
+	names := make([]string, 0, len(kinds))
+	for name := range kinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var missing []string
+	docs := make([]varDoc, len(names))
+	for i, name := range names {
+		docs[i] = varDoc{name, kinds[name], supplied[name]}
+		if !supplied[name] && kinds[name][0] == "required" {
+			missing = append(missing, name)
+		}
+	}
+
+	if format == jsonFormat {
+		err = printJSON(os.Stdout, struct {
+			Lang string   `json:"language"`
+			Vars []varDoc `json:"variables"`
+		}{lang, docs})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
+	} else {
+		for _, doc := range docs {
+			line := doc.Name + "\t" + strings.Join(doc.Kinds, ",")
+			if !doc.Supplied {
+				line += "\tunsupplied"
+			}
+			fmt.Println(line)
+		}
+	}
+
+	if len(missing) > 0 {
+		fmt.Fprintf(os.Stderr, "Required variables aren't supplied: %s\n",
+			strings.Join(missing, ", "))
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// suppliedVars returns the names of the variables that bake can supply to the
+// templates of `r`.
+func suppliedVars(r recipe.Recipe) (map[string]bool, error) {
+	supplied := map[string]bool{proj.TypesVar: true}
+	for name := range makeProjVars() {
+		supplied[name] = true
+	}
+	for name := range optionalArgs {
+		supplied[name] = true
+	}
+
+	derived, err := r.DerivedVars()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range derived {
+		supplied[v.Name] = true
+	}
+
+	typeNames, err := r.Types()
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range append(typeNames, recipe.BaseType) {
+		supplied[t] = true
+	}
+
+	return supplied, nil
+}
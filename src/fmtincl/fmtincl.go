@@ -6,6 +6,7 @@ package main
 
 import (
 	"bake/proj"
+	"bake/recipe"
 	"fmt"
 	"os"
 	"strings"
@@ -22,13 +23,21 @@ func main() {
 	}
 
 	fname := os.Args[1]
-	node, err := proj.ParseInclFiles(fname)
+	header, err := recipe.ParseInclHeader(fname)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing '%s': %v\n", fname, err)
+		os.Exit(2)
+	}
 
+	node, err := proj.ParseInclFiles(fname)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error parsing '%s': %v\n", fname, err)
 		os.Exit(2)
 	}
 
+	// The description is output by fmtincl.sh
+	fmt.Print(header.String())
+
 	str := node.String()
 	if len(str) > 2 {
 		str := strings.Replace(str[3:], "\n\t", "\n", -1)
@@ -5,6 +5,7 @@
 package fs
 
 import (
+	"fmt"
 	"sort"
 	"strings"
 )
@@ -13,26 +14,42 @@ const (
 	// `dirSep` is simply for formatting directory paths, and is independent
 	// of the actual platform being used.
 	dirSep = "/"
+
+	// `condFmt` formats the condition under which a node is included.
+	condFmt = " {?%s}"
 )
 
 type Node struct {
 	isDir    bool
 	name     string
+	cond     string
 	children []*Node
 }
 
 func NewFile(name string) *Node {
-	return &Node{false, name, nil}
+	return &Node{false, name, "", nil}
 }
 
 func NewDir(name string, children ...*Node) *Node {
-	return &Node{true, name, children}
+	return &Node{true, name, "", children}
 }
 
 func (n *Node) Name() string {
 	return n.name
 }
 
+// Cond returns the condition under which `n` is included, or "" if `n` is
+// always included.
+func (n *Node) Cond() string {
+	return n.cond
+}
+
+// SetCond sets the condition under which `n` is included and returns `n`.
+func (n *Node) SetCond(cond string) *Node {
+	n.cond = cond
+	return n
+}
+
 func (n *Node) Children() []*Node {
 	return n.children
 }
@@ -78,9 +95,13 @@ func (n *Node) AddFile(name string) *Node {
 
 func (n *Node) String() string {
 	s := n.name
-	if n.children != nil {
+	if n.isDir {
 		s += dirSep
-
+	}
+	if len(n.cond) > 0 {
+		s += fmt.Sprintf(condFmt, n.cond)
+	}
+	if n.isDir {
 		names := make([]string, len(n.children))
 		for i, c := range n.children {
 			names[i] = c.name
@@ -5,7 +5,7 @@
 package main
 
 import (
-	"bake/recipe"
+	"bake/proj"
 	"fmt"
 	"os"
 	"time"
@@ -20,7 +20,7 @@ func main() {
 	exitStatus := 0
 
 	for _, lang := range testLangs {
-		if passed, err := recipe.Test(lang); err != nil {
+		if passed, err := proj.Test(lang); err != nil {
 			fmt.Fprintf(os.Stderr, "%v\n", err)
 			exitStatus = 1
 		} else if !passed {